@@ -0,0 +1,122 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "sort"
+   "time"
+   "strings"
+   "path"
+   "path/filepath"
+   "github.com/GlenKelley/dev/plugin"
+)
+
+// AggregateFunc combines many files into new outputs. Sources and outputs are
+// slash separated paths relative to srcDir and buildDir, and the returned
+// files are relative to buildDir.
+type AggregateFunc func(srcDir string, buildDir string, sources []string, outputs []string) ([]plugin.File, error)
+
+// Aggregate is a build stage that runs after every source has been
+// processed, with the sources and build outputs matching its globs. Globs use
+// path.Match syntax, where a "**" segment also matches any number of
+// directories.
+type Aggregate struct {
+    Name string
+    Sources []string
+    Outputs []string
+    Run AggregateFunc
+}
+
+type AggregateResult struct {
+    Name string
+    Inputs int
+    Outputs []string
+    Duration time.Duration
+}
+
+// Aggregate adds a stage to run after the per-file handlers. Stages run in the
+// order they are added, before stages from the site config.
+func (b *Builder) Aggregate(a Aggregate) {
+    b.aggregates = append(b.aggregates, a)
+}
+
+func (b *Builder) allAggregates() []Aggregate {
+    aggregates := append([]Aggregate{}, b.aggregates...)
+    names := []string{}
+    for name := range b.Options.Site.Aggregates {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        aggregates = append(aggregates, b.pluginAggregate(name, b.Options.Site.Aggregates[name]))
+    }
+    return aggregates
+}
+
+func (b *Builder) runAggregates(srcDir string, buildDir string) ([]AggregateResult, error) {
+    results := []AggregateResult{}
+    for _, a := range b.allAggregates() {
+        started := time.Now()
+        sources, sourceTime, err := matchFiles(srcDir, a.Sources)
+        if err != nil { return results, err }
+        outputs, outputTime, err := matchFiles(buildDir, a.Outputs)
+        if err != nil { return results, err }
+
+        files, err := a.Run(srcDir, buildDir, sources, outputs)
+        if err != nil { return results, fmt.Errorf("aggregate %s: %s", a.Name, err) }
+
+        modTime := sourceTime
+        if outputTime.After(modTime) {
+            modTime = outputTime
+        }
+        err = b.finishOutputs(a.Name, buildDir, "", files, modTime)
+        if err != nil { return results, err }
+
+        paths := []string{}
+        for _, file := range files {
+            paths = append(paths, file.Path)
+        }
+        results = append(results, AggregateResult{a.Name, len(sources) + len(outputs), paths, time.Since(started)})
+    }
+    return results, nil
+}
+
+// matchFiles lists the files under dir matching any of the globs, with the
+// newest modification time among them.
+func matchFiles(dir string, globs []string) ([]string, time.Time, error) {
+    matches := []string{}
+    modTime := time.Time{}
+    if len(globs) == 0 { return matches, modTime, nil }
+    err := filepath.Walk(dir, func (p string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.Name() == ".git" && info.IsDir() { return filepath.SkipDir }
+        if info.IsDir() { return nil }
+        relativePath, err := filepath.Rel(dir, p)
+        if err != nil { return err }
+        name := filepath.ToSlash(relativePath)
+        for _, glob := range globs {
+            if matchGlob(strings.Split(glob, "/"), strings.Split(name, "/")) {
+                matches = append(matches, name)
+                if info.ModTime().After(modTime) {
+                    modTime = info.ModTime()
+                }
+                break
+            }
+        }
+        return nil
+    })
+    return matches, modTime, err
+}
+
+func matchGlob(pattern []string, name []string) bool {
+    if len(pattern) == 0 { return len(name) == 0 }
+    if pattern[0] == "**" {
+        for i := 0; i <= len(name); i++ {
+            if matchGlob(pattern[1:], name[i:]) { return true }
+        }
+        return false
+    }
+    if len(name) == 0 { return false }
+    matched, err := path.Match(pattern[0], name[0])
+    return err == nil && matched && matchGlob(pattern[1:], name[1:])
+}
@@ -0,0 +1,31 @@
+package build
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+    tests := []struct {
+        pattern string
+        name string
+        match bool
+    }{
+        {"*.js", "app.js", true},
+        {"*.js", "js/app.js", false},
+        {"js/*.js", "js/app.js", true},
+        {"js/*.js", "js/lib/app.js", false},
+        {"js/**/*.js", "js/app.js", true},
+        {"js/**/*.js", "js/lib/vendor/app.js", true},
+        {"**", "index.html", true},
+        {"**/index.html", "blog/2014/index.html", true},
+        {"**/index.html", "blog/about.html", false},
+        {"img/?.png", "img/a.png", true},
+        {"img/[", "img/[", false},
+    }
+    for _, test := range tests {
+        if match := matchGlob(strings.Split(test.pattern, "/"), strings.Split(test.name, "/")); match != test.match {
+            t.Errorf("matchGlob(%q, %q) = %v, want %v", test.pattern, test.name, match, test.match)
+        }
+    }
+}
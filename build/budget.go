@@ -0,0 +1,211 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "sort"
+   "bytes"
+   "strings"
+   "strconv"
+   "io/ioutil"
+   "encoding/json"
+   "compress/gzip"
+   "path/filepath"
+   "text/tabwriter"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/linkcheck"
+   "github.com/GlenKelley/dev/s3"
+)
+
+// SizeReport records the sizes of a build's outputs so the next build can
+// show how they changed. Pages holds the total weight of each HTML page and
+// the files it references, as served.
+type SizeReport struct {
+    Files map[string]FileSize `json:"files"`
+    Pages map[string]int64 `json:"pages"`
+}
+
+type FileSize struct {
+    Raw int64 `json:"raw"`
+    Gzip int64 `json:"gzip"`
+    Served int64 `json:"served"`
+}
+
+func reportPath(outputDir string) string {
+    return outputDir + ".report.json"
+}
+
+func readSizeReport(path string) (SizeReport, error) {
+    report := SizeReport{map[string]FileSize{}, map[string]int64{}}
+    bs, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) { return report, nil }
+    if err != nil { return report, err }
+    err = json.Unmarshal(bs, &report)
+    return report, err
+}
+
+func writeSizeReport(path string, report SizeReport) error {
+    bs, err := json.MarshalIndent(report, "", "  ")
+    if err != nil { return err }
+    return ioutil.WriteFile(path, bs, dataMode)
+}
+
+func measureOutputs(buildDir string) (SizeReport, error) {
+    report := SizeReport{map[string]FileSize{}, map[string]int64{}}
+    pages := []string{}
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() { return nil }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        file := filepath.ToSlash(relativePath)
+        stored, err := ioutil.ReadFile(path)
+        if err != nil { return err }
+        size := FileSize{int64(len(stored)), int64(len(stored)), int64(len(stored))}
+        if isGzipped(stored) {
+            content, err := readBuildFile(path)
+            if err != nil { return err }
+            size.Raw = int64(len(content))
+        } else {
+            size.Gzip, err = gzipSize(stored)
+            if err != nil { return err }
+        }
+        report.Files[file] = size
+        if filepath.Ext(file) == ".html" {
+            pages = append(pages, file)
+        }
+        return nil
+    })
+    if err != nil { return report, err }
+
+    keys := map[string]string{}
+    for file := range report.Files {
+        keys[filepath.ToSlash(s3.ItemPath(file))] = file
+    }
+    for _, page := range pages {
+        content, err := readBuildFile(filepath.Join(buildDir, filepath.FromSlash(page)))
+        if err != nil { return report, err }
+        included := map[string]bool{page: true}
+        weight := report.Files[page].Served
+        for _, ref := range linkcheck.Extract(page, content) {
+            if !linkcheck.IsInternal(ref.URL) { continue }
+            file, found := keys[linkcheck.Resolve(s3.ItemPath(page), ref.URL)]
+            if !found || included[file] || filepath.Ext(file) == ".html" { continue }
+            included[file] = true
+            weight += report.Files[file].Served
+        }
+        report.Pages[page] = weight
+    }
+    return report, nil
+}
+
+func gzipSize(content []byte) (int64, error) {
+    var buffer bytes.Buffer
+    writer := gzip.NewWriter(&buffer)
+    _, err := writer.Write(content)
+    if err != nil { return 0, err }
+    err = writer.Close()
+    return int64(buffer.Len()), err
+}
+
+type budgetOffender struct {
+    file string
+    kind string
+    limit int64
+    size int64
+    previous int64
+    known bool
+}
+
+// checkBudgets compares the build's sizes with the site's budgets, printing
+// a table of the files over budget with their change since the previous
+// report.
+func checkBudgets(budgets []config.Budget, report SizeReport, previous SizeReport) error {
+    offenders := []budgetOffender{}
+    files := []string{}
+    for file := range report.Files {
+        files = append(files, file)
+    }
+    sort.Strings(files)
+    for _, budget := range budgets {
+        limits := map[string]string{"raw": budget.Raw, "gzip": budget.Gzip, "page": budget.Page}
+        for _, kind := range []string{"raw", "gzip", "page"} {
+            if limits[kind] == "" { continue }
+            limit, err := parseSize(limits[kind])
+            if err != nil { return fmt.Errorf("budget %s: %s", budget.Glob, err) }
+            for _, file := range files {
+                if !matchGlob(strings.Split(budget.Glob, "/"), strings.Split(file, "/")) { continue }
+                if kind == "page" && filepath.Ext(file) != ".html" { continue }
+                size, previousSize, known := measured(kind, file, report, previous)
+                if size > limit {
+                    offenders = append(offenders, budgetOffender{file, kind, limit, size, previousSize, known})
+                }
+            }
+        }
+    }
+    if len(offenders) == 0 { return nil }
+
+    writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(writer, "FILE\tBUDGET\tLIMIT\tSIZE\tDELTA")
+    for _, o := range offenders {
+        delta := "new"
+        if o.known {
+            delta = formatDelta(o.size - o.previous)
+        }
+        fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", o.file, o.kind, formatSize(o.limit), formatSize(o.size), delta)
+    }
+    writer.Flush()
+    return fmt.Errorf("%d size budgets exceeded", len(offenders))
+}
+
+func measured(kind string, file string, report SizeReport, previous SizeReport) (int64, int64, bool) {
+    switch kind {
+    case "raw":
+        old, known := previous.Files[file]
+        return report.Files[file].Raw, old.Raw, known
+    case "gzip":
+        old, known := previous.Files[file]
+        return report.Files[file].Gzip, old.Gzip, known
+    }
+    old, known := previous.Pages[file]
+    return report.Pages[file], old, known
+}
+
+var sizeUnits = []struct {
+    suffix string
+    bytes float64
+}{
+    {"MB", 1024 * 1024},
+    {"KB", 1024},
+    {"B", 1},
+}
+
+// parseSize reads sizes like "150KB", "1.5 MB" or "2048".
+func parseSize(value string) (int64, error) {
+    number := strings.ToUpper(strings.TrimSpace(value))
+    scale := float64(1)
+    for _, unit := range sizeUnits {
+        if strings.HasSuffix(number, unit.suffix) {
+            number = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix))
+            scale = unit.bytes
+            break
+        }
+    }
+    n, err := strconv.ParseFloat(number, 64)
+    if err != nil || n < 0 { return 0, fmt.Errorf("invalid size %q", value) }
+    return int64(n * scale), nil
+}
+
+func formatSize(n int64) string {
+    for _, unit := range sizeUnits {
+        if float64(n) >= unit.bytes && unit.bytes > 1 {
+            return fmt.Sprintf("%.1f %s", float64(n) / unit.bytes, unit.suffix)
+        }
+    }
+    return fmt.Sprintf("%d B", n)
+}
+
+func formatDelta(n int64) string {
+    if n < 0 { return "-" + formatSize(-n) }
+    return "+" + formatSize(n)
+}
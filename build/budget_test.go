@@ -0,0 +1,68 @@
+package build
+
+import (
+    "testing"
+    "github.com/GlenKelley/dev/config"
+)
+
+func TestParseSize(t *testing.T) {
+    tests := []struct {
+        value string
+        size int64
+        err string
+    }{
+        {"2048", 2048, ""},
+        {"150KB", 150 * 1024, ""},
+        {"1.5 MB", 1536 * 1024, ""},
+        {" 10kb ", 10 * 1024, ""},
+        {"0B", 0, ""},
+        {"KB", 0, `invalid size "KB"`},
+        {"-1KB", 0, `invalid size "-1KB"`},
+        {"10 GB", 0, `invalid size "10 GB"`},
+    }
+    for _, test := range tests {
+        size, err := parseSize(test.value)
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if size != test.size || message != test.err {
+            t.Errorf("parseSize(%q) = %d, %q, want %d, %q", test.value, size, message, test.size, test.err)
+        }
+    }
+}
+
+func TestCheckBudgets(t *testing.T) {
+    report := SizeReport{
+        Files: map[string]FileSize{
+            "index.html": {Raw: 4000, Gzip: 1000, Served: 1000},
+            "js/app.js": {Raw: 300 * 1024, Gzip: 90 * 1024, Served: 90 * 1024},
+            "js/lib/vendor.js": {Raw: 50 * 1024, Gzip: 20 * 1024, Served: 20 * 1024},
+        },
+        Pages: map[string]int64{"index.html": 111 * 1024},
+    }
+    previous := SizeReport{Files: map[string]FileSize{"js/app.js": {Raw: 200 * 1024}}, Pages: map[string]int64{}}
+
+    tests := []struct {
+        budgets []config.Budget
+        err string
+    }{
+        {nil, ""},
+        {[]config.Budget{{Glob: "js/**/*.js", Raw: "400KB", Gzip: "100KB"}}, ""},
+        {[]config.Budget{{Glob: "js/*.js", Raw: "100KB"}}, "1 size budgets exceeded"},
+        {[]config.Budget{{Glob: "**/*.js", Raw: "40KB"}}, "2 size budgets exceeded"},
+        {[]config.Budget{{Glob: "**", Page: "100KB"}}, "1 size budgets exceeded"},
+        {[]config.Budget{{Glob: "*.html", Gzip: "1KB", Page: "200KB"}}, ""},
+        {[]config.Budget{{Glob: "*.css", Raw: "lots"}}, `budget *.css: invalid size "lots"`},
+    }
+    for _, test := range tests {
+        err := checkBudgets(test.budgets, report, previous)
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err {
+            t.Errorf("checkBudgets(%v) = %q, want %q", test.budgets, message, test.err)
+        }
+    }
+}
@@ -0,0 +1,385 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "time"
+   "strings"
+   "strconv"
+   "io/ioutil"
+   "regexp"
+   "path/filepath"
+   "github.com/GlenKelley/dev/command"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/lock"
+   "github.com/GlenKelley/dev/redirect"
+)
+
+var identifierPattern = regexp.MustCompile("[^A-Za-z0-9_]")
+
+type Options struct {
+    Env string
+    SiteURL string
+    GoTargets []string
+    Commit string
+    KeepModelSources bool
+    NoCompress bool
+    ClosureDir string
+    Reproducible bool
+    SourceDate time.Time
+    Site config.Site
+    Executor command.Executor
+}
+
+type Builder struct {
+    Options Options
+    handlers map[string]ProcessFile
+    extensions map[string]string
+    aggregates []Aggregate
+    // compression queues the current build's outputs to gzip at the end.
+    compression *compressionQueue
+    // sources maps the current build's outputs back to their sources.
+    sources *sourceMap
+}
+
+type Result struct {
+    SrcDir string
+    BuildDir string
+    Started time.Time
+    Duration time.Duration
+    Sources []SourceResult
+    Unhandled []string
+    Aggregates []AggregateResult
+    Outputs []OutputResult
+}
+
+type SourceResult struct {
+    Path string
+    Handler string
+    Duration time.Duration
+    Err error
+}
+
+type OutputResult struct {
+    Path string
+    Size int64
+}
+
+func NewBuilder(options Options) *Builder {
+    return &Builder{options, map[string]ProcessFile{}, map[string]string{}, nil, &compressionQueue{}, &sourceMap{}}
+}
+
+// Register makes handler available by name to site configs and uses it for
+// files with the given extensions.
+func (b *Builder) Register(name string, handler ProcessFile, exts ...string) {
+    b.handlers[name] = handler
+    for _, ext := range exts {
+        b.extensions[ext] = name
+    }
+}
+
+func (b *Builder) executor() command.Executor {
+    if b.Options.Executor == nil {
+        return command.System{}
+    }
+    return b.Options.Executor
+}
+
+func (b *Builder) siteURL() string {
+    if b.Options.Site.URL != "" {
+        return strings.TrimSuffix(b.Options.Site.URL, "/")
+    }
+    return b.Options.SiteURL
+}
+
+func (b *Builder) Build(srcDir string, buildDir string) (Result, error) {
+    options := b.Options
+    result := Result{SrcDir: srcDir, BuildDir: buildDir, Started: time.Now()}
+    b.compression = &compressionQueue{}
+    b.sources = &sourceMap{}
+    restoreEnv := setEnv(options.Site.Env)
+    defer restoreEnv()
+
+    err := b.runHooks("pre-build", options.Site.Hooks.Pre, srcDir, buildDir)
+    if err != nil { return result, err }
+
+    shaders := NewShaderBundle()
+    assets := NewAssetManifest(buildDir)
+    handlers, names, err := b.fileHandlers(shaders, assets)
+    if err != nil { return result, err }
+    c, err := walkDir(srcDir, buildDir, handlers, names, &result)
+    if err != nil { return result, err }
+    err = <- c
+    if err != nil { return result, err }
+
+    if options.Reproducible {
+        err = normalizeTree(buildDir, options.SourceDate)
+        if err != nil { return result, err }
+    }
+
+    err = shaders.Write(buildDir, b.compression)
+    if err != nil { return result, err }
+    err = assets.Write(b.compression)
+    if err != nil { return result, err }
+    err = writeBundles(buildDir, options.Site.Bundles, b.compression)
+    if err != nil { return result, err }
+    result.Aggregates, err = b.runAggregates(srcDir, buildDir)
+    if err != nil { return result, err }
+    err = lintOutputs(srcDir, buildDir, options.Site.Lint)
+    if err != nil { return result, err }
+
+    err = addIntegrity(buildDir)
+    if err != nil { return result, err }
+
+    err = writeSitemap(buildDir, b.siteURL(), b.compression)
+    if err != nil { return result, err }
+    err = writeRobots(buildDir, options.Env, b.siteURL())
+    if err != nil { return result, err }
+
+    err = b.compressPending()
+    if err != nil { return result, err }
+    redirects, err := copyRedirects(srcDir, buildDir)
+    if err != nil { return result, err }
+    err = checkLinks(srcDir, buildDir, redirects, b.sources)
+    if err != nil { return result, err }
+    err = b.runHooks("post-build", options.Site.Hooks.Post, srcDir, buildDir)
+    if err != nil { return result, err }
+
+    if options.Reproducible {
+        err = normalizeTree(buildDir, options.SourceDate)
+        if err != nil { return result, err }
+    }
+
+    result.Outputs, err = listOutputs(buildDir)
+    result.Duration = time.Since(result.Started)
+    return result, err
+}
+
+// BuildInto builds srcDir in a temporary directory and then, holding
+// outputDir's lock, checks the size budgets against outputDir's report and
+// swaps the result and its new report into place.
+func (b *Builder) BuildInto(srcDir string, outputDir string) (Result, error) {
+    err := checkOutputDir(srcDir, outputDir)
+    if err != nil { return Result{}, err }
+    err = removeStaleBuildDirs()
+    if err != nil { return Result{}, err }
+    buildDir, err := mkdirTemp()
+    if err != nil { return Result{}, err }
+    defer os.RemoveAll(buildDir)
+
+    result, err := b.Build(srcDir, buildDir)
+    if err != nil { return result, err }
+
+    report, err := measureOutputs(buildDir)
+    if err != nil { return result, err }
+
+    err = MkdirAll(filepath.Dir(outputDir))
+    if err != nil { return result, err }
+    outputLock, err := lock.Acquire(outputDir + ".lock")
+    if err != nil { return result, err }
+    defer outputLock.Release()
+
+    previous, err := readSizeReport(reportPath(outputDir))
+    if err != nil { return result, err }
+    err = checkBudgets(b.Options.Site.Budgets, report, previous)
+    if err != nil { return result, err }
+    err = b.swapDeployDir(buildDir, outputDir)
+    if err != nil { return result, err }
+    return result, writeSizeReport(reportPath(outputDir), report)
+}
+
+func listOutputs(buildDir string) ([]OutputResult, error) {
+    outputs := []OutputResult{}
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() { return nil }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        outputs = append(outputs, OutputResult{filepath.ToSlash(relativePath), info.Size()})
+        return nil
+    })
+    return outputs, err
+}
+
+func setEnv(env map[string]string) func() {
+    previous := map[string]*string{}
+    for key, value := range env {
+        old, found := os.LookupEnv(key)
+        previous[key] = nil
+        if found {
+            previous[key] = &old
+        }
+        os.Setenv(key, value)
+    }
+    return func() {
+        for key, old := range previous {
+            if old == nil {
+                os.Unsetenv(key)
+            } else {
+                os.Setenv(key, *old)
+            }
+        }
+    }
+}
+
+// runHooks runs each hook with sh, describing the build in BUILD_SRC_DIR,
+// BUILD_DIR, BUILD_ENV and BUILD_SITE, and stops at the first that fails.
+func (b *Builder) runHooks(stage string, hooks []string, srcDir string, buildDir string) error {
+    env := append(os.Environ(),
+        "BUILD_SRC_DIR=" + srcDir,
+        "BUILD_DIR=" + buildDir,
+        "BUILD_ENV=" + b.Options.Env,
+        "BUILD_SITE=" + b.Options.Site.Name)
+    for _, hook := range hooks {
+        fmt.Printf("running %s hook %s\n", stage, hook)
+        cmd := command.New("sh", "-c", hook)
+        cmd.Dir = srcDir
+        cmd.Env = env
+        err := b.runCommand(cmd)
+        if err != nil { return fmt.Errorf("%s hook %q failed: %s", stage, hook, err) }
+    }
+    return nil
+}
+
+const buildDirPrefix = "dev-build-"
+
+func mkdirTemp() (string, error) {
+    dirPath, err := ioutil.TempDir("", fmt.Sprintf("%s%d-", buildDirPrefix, os.Getpid()))
+    if err != nil { return "", err }
+    err = os.Chmod(dirPath, 0755)
+    return dirPath, err
+}
+
+func removeStaleBuildDirs() error {
+    paths, err := filepath.Glob(filepath.Join(os.TempDir(), buildDirPrefix + "*"))
+    if err != nil { return err }
+    for _, path := range paths {
+        parts := strings.SplitN(strings.TrimPrefix(filepath.Base(path), buildDirPrefix), "-", 2)
+        pid, err := strconv.Atoi(parts[0])
+        if err != nil || lock.ProcessAlive(pid) { continue }
+        fmt.Printf("removing stale build directory %s\n", path)
+        err = os.RemoveAll(path)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+// checkOutputDir refuses an output directory that equals or contains srcDir
+// or its git repository, since the swap removes the old output first, and one
+// inside srcDir, whose old output the next build would read as sources.
+func checkOutputDir(srcDir string, outputDir string) error {
+    output, err := realPath(outputDir)
+    if err != nil { return err }
+    src, err := realPath(srcDir)
+    if err != nil { return err }
+    relativePath, err := filepath.Rel(src, output)
+    if err != nil { return err }
+    if relativePath != "." && !isOutside(relativePath) {
+        return fmt.Errorf("refusing output directory %s inside the source directory %s", outputDir, srcDir)
+    }
+    protected := []string{src}
+    for dir := src; ; dir = filepath.Dir(dir) {
+        if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+            protected = append(protected, dir)
+            break
+        }
+        if filepath.Dir(dir) == dir { break }
+    }
+    for _, dir := range protected {
+        relativePath, err := filepath.Rel(output, dir)
+        if err != nil { return err }
+        if !isOutside(relativePath) {
+            return fmt.Errorf("refusing to replace output directory %s, which contains %s", outputDir, dir)
+        }
+    }
+    return nil
+}
+
+// isOutside reports whether a path relative to a directory leaves it.
+func isOutside(relativePath string) bool {
+    return relativePath == ".." || strings.HasPrefix(relativePath, ".." + string(filepath.Separator))
+}
+
+// realPath makes path absolute and resolves symlinks in the part of it that
+// exists.
+func realPath(path string) (string, error) {
+    path, err := filepath.Abs(path)
+    if err != nil { return "", err }
+    resolved, err := filepath.EvalSymlinks(path)
+    if err == nil { return resolved, nil }
+    if !os.IsNotExist(err) { return "", err }
+    if filepath.Dir(path) == path { return path, nil }
+    parent, err := realPath(filepath.Dir(path))
+    if err != nil { return "", err }
+    return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// swapDeployDir replaces deployDir with buildDir. The caller holds
+// deployDir's lock.
+func (b *Builder) swapDeployDir(buildDir string, deployDir string) error {
+    err := os.RemoveAll(deployDir)
+    if err != nil { return err }
+    return b.executor().Run(command.New("mv", buildDir, deployDir))
+}
+
+// ignoredSources are files and directories in a site that are read by the
+// build rather than handled as sources.
+var ignoredSources = map[string]bool {
+   ".git":true,
+   layoutDir:true,
+   config.File:true,
+   redirect.File:true,
+   localeDir:true,
+}
+
+func walkDir(srcDir string, buildDir string, handlers map[string]ProcessFile, names map[string]string, result *Result) (chan error, error) {
+    onFinish := make(chan SourceResult)
+    run := func (f ProcessFile, path string, info os.FileInfo) {
+        started := time.Now()
+        err := f(srcDir, buildDir, path, info)
+        if err != nil { fmt.Printf("%s : %s\n", path, err) }
+        relativePath, _ := filepath.Rel(srcDir, path)
+        handler := names[filepath.Ext(path)]
+        onFinish <- SourceResult{relativePath, handler, time.Since(started), err}
+    }
+
+    visited := 0
+    err := filepath.Walk(srcDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { 
+            fmt.Println(err)
+            return nil  
+        }
+        if ignoredSources[info.Name()] {
+            if info.IsDir() { return filepath.SkipDir }
+            return nil
+        }
+        if info.IsDir() { return nil }
+        ext := filepath.Ext(info.Name())
+        handler := handlers[ext]
+        if handler != nil {
+            visited++;
+            go run(handler, path, info)
+        } else {
+            fmt.Printf("unknown ext %s\n", ext)
+            relativePath, _ := filepath.Rel(srcDir, path)
+            result.Unhandled = append(result.Unhandled, relativePath)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    finished := make(chan error)
+    go func(v int) {
+        fail := error(nil)
+        for ; v > 0; v-- {
+            source := <- onFinish
+            result.Sources = append(result.Sources, source)
+            if source.Err != nil {
+               fail = source.Err
+            }
+        }
+        finished <- fail
+    }(visited)
+    return finished, nil
+}
@@ -0,0 +1,96 @@
+package build
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "github.com/GlenKelley/dev/linkcheck"
+)
+
+func TestCheckOutputDir(t *testing.T) {
+    root, err := ioutil.TempDir("", "output-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(root)
+    repo := filepath.Join(root, "repo")
+    srcDir := filepath.Join(repo, "site")
+    err = os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+    if err != nil { t.Fatal(err) }
+    err = os.MkdirAll(srcDir, 0755)
+    if err != nil { t.Fatal(err) }
+
+    tests := []struct {
+        output string
+        ok bool
+    }{
+        {srcDir, false},
+        {repo, false},
+        {root, false},
+        {"/", false},
+        {filepath.Join(srcDir, "..", "site"), false},
+        {filepath.Join(root, "out"), true},
+        {filepath.Join(root, "repo-out"), true},
+        {filepath.Join(srcDir, "out"), false},
+        {filepath.Join(srcDir, "public", "site"), false},
+        {filepath.Join(root, "missing", "out"), true},
+    }
+    for _, test := range tests {
+        err := checkOutputDir(srcDir, test.output)
+        if (err == nil) != test.ok {
+            t.Errorf("checkOutputDir(%q): %v, want ok %v", test.output, err, test.ok)
+        }
+    }
+}
+
+func TestCompressPending(t *testing.T) {
+    buildDir, err := ioutil.TempDir("", "compress-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(buildDir)
+
+    builders := []*Builder{NewBuilder(Options{}), NewBuilder(Options{}), NewBuilder(Options{NoCompress: true})}
+    paths := []string{}
+    for i, b := range builders {
+        path := filepath.Join(buildDir, fmt.Sprintf("%d.js", i))
+        paths = append(paths, path)
+        err = ioutil.WriteFile(path, []byte("var x = 1;"), dataMode)
+        if err != nil { t.Fatal(err) }
+        err = b.compress(&Artifact{Path: path})
+        if err != nil { t.Fatal(err) }
+    }
+
+    for _, i := range []int{0, 2} {
+        err = builders[i].compressPending()
+        if err != nil { t.Fatal(err) }
+    }
+    for i, want := range []bool{true, false, false} {
+        content, err := ioutil.ReadFile(paths[i])
+        if err != nil { t.Fatal(err) }
+        if isGzipped(content) != want {
+            t.Errorf("%s gzipped %v, want %v", paths[i], isGzipped(content), want)
+        }
+    }
+}
+
+func TestBrokenLink(t *testing.T) {
+    srcDir := filepath.FromSlash("/site")
+    buildDir := filepath.FromSlash("/tmp/out")
+    sources := &sourceMap{}
+    sources.add(filepath.Join(buildDir, "about.html"), filepath.Join(srcDir, "about.html"), true)
+    sources.add(filepath.Join(buildDir, "about.html"), filepath.Join(srcDir, "about.html"), false)
+    sources.add(filepath.Join(buildDir, "posts", "a.html"), filepath.Join(srcDir, "posts", "a.md"), false)
+
+    tests := []struct {
+        ref linkcheck.Reference
+        report string
+    }{
+        {linkcheck.Reference{File: "about.html", Line: 3, URL: "/gone"}, "about.html:3: broken link /gone (output about.html)"},
+        {linkcheck.Reference{File: "posts/a.html", Line: 12, URL: "b"}, "posts/a.md: broken link b (output posts/a.html:12)"},
+        {linkcheck.Reference{File: "sitemap.html", Line: 1, URL: "/x"}, "output sitemap.html:1: broken link /x"},
+    }
+    for _, test := range tests {
+        if report := brokenLink(srcDir, buildDir, sources, test.ref); report != test.report {
+            t.Errorf("brokenLink(%v) = %q, want %q", test.ref, report, test.report)
+        }
+    }
+}
@@ -0,0 +1,116 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "sort"
+   "path/filepath"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/toolchain"
+)
+
+var buildTools = []string{"git", "cp", "mv", "gzip"}
+var deployTools = []string{"md5"}
+var optionalTools = []string{"java"}
+
+var handlerTools = map[string][]string {
+    "less": []string{"lessc"},
+    "markdown": []string{"markdown"},
+    "coffee": []string{"coffee"},
+    "coffeejson": []string{"coffee", "perl"},
+    "go": []string{"go"},
+}
+
+// Doctor checks for the tools that building sites needs.
+func Doctor(root string, sites []config.Site) bool {
+    names := requiredTools(root, sites)
+    names = append(names, deployTools...)
+    names = append(names, optionalTools...)
+
+    ok := true
+    for _, name := range names {
+        tool, found := toolchain.Tools[name]
+        if !found {
+            tool = toolchain.Tool{Name: name}
+        }
+        status := toolchain.Check(tool)
+        if status.OK() {
+            fmt.Printf("ok       %-9s %s\n", name, status.Version)
+            continue
+        }
+        label := "missing "
+        if containsString(optionalTools, name) {
+            label = "optional"
+        } else {
+            ok = false
+        }
+        fmt.Printf("%s %-9s %s\n", label, name, status.Err)
+        suggestion := status.Suggestion()
+        if suggestion != "" {
+            fmt.Printf("         %-9s install: %s\n", "", suggestion)
+        }
+    }
+    return ok
+}
+
+// requiredTools lists the tools a build of sites runs: the build's own, those
+// used by the handlers of the source files under each site's root, and the
+// commands of its plugins and aggregates.
+func requiredTools(root string, sites []config.Site) []string {
+    names := append([]string{}, buildTools...)
+    add := func(name string) {
+        if !containsString(names, name) {
+            names = append(names, name)
+        }
+    }
+    for _, site := range sites {
+        srcDir := filepath.Join(root, site.Root)
+        handlers := handlerNames(site, nil)
+        extensions, err := sourceExtensions(srcDir)
+        if err != nil { fmt.Printf("cannot list sources: %s\n", err) }
+        for _, ext := range extensions {
+            handler := handlers[ext]
+            tools := handlerTools[handler]
+            if stages := site.Pipelines[handler]; len(stages) > 0 {
+                tools = handlerTools[stages[0]]
+            }
+            for _, name := range tools {
+                add(name)
+            }
+            if p, found := site.Plugins[handler]; found {
+                add(pluginCommand(srcDir, p.Command))
+            }
+        }
+        for _, p := range site.Aggregates {
+            add(pluginCommand(srcDir, p.Command))
+        }
+    }
+    sort.Strings(names[len(buildTools):])
+    return names
+}
+
+// sourceExtensions lists the extensions of the sources a build of srcDir
+// would handle.
+func sourceExtensions(srcDir string) ([]string, error) {
+    extensions := []string{}
+    err := filepath.Walk(srcDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if ignoredSources[info.Name()] {
+            if info.IsDir() { return filepath.SkipDir }
+            return nil
+        }
+        ext := filepath.Ext(info.Name())
+        if !info.IsDir() && !containsString(extensions, ext) {
+            extensions = append(extensions, ext)
+        }
+        return nil
+    })
+    return extensions, err
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value { return true }
+    }
+    return false
+}
@@ -0,0 +1,46 @@
+package build
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "github.com/GlenKelley/dev/config"
+)
+
+func TestRequiredTools(t *testing.T) {
+    root, err := ioutil.TempDir("", "doctor-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(root)
+    files := []string{"index.html", "css/site.less", "_layouts/page.md", ".git/HEAD", "models/a.dae", "notes/a.txt"}
+    for _, file := range files {
+        path := filepath.Join(root, "site", filepath.FromSlash(file))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        err = ioutil.WriteFile(path, nil, 0644)
+        if err != nil { t.Fatal(err) }
+    }
+
+    tests := []struct {
+        name string
+        site config.Site
+        tools []string
+    }{
+        {"sources present", config.Site{Root: "site"}, []string{"lessc"}},
+        {"handler override", config.Site{Root: "site", Handlers: map[string]string{".html": "markdown"}}, []string{"lessc", "markdown"}},
+        {"pipeline", config.Site{Root: "site", Handlers: map[string]string{".txt": "notes"}, Pipelines: map[string][]string{"notes": []string{"coffee", "compress"}}}, []string{"coffee", "lessc"}},
+        {"plugins and aggregates", config.Site{
+            Root: "site",
+            Plugins: map[string]config.Plugin{"text": config.Plugin{Command: "./tools/text", Extensions: []string{".txt"}}, "unused": config.Plugin{Command: "unused", Extensions: []string{".xyz"}}},
+            Aggregates: map[string]config.Plugin{"search": config.Plugin{Command: "indexer"}},
+        }, []string{filepath.Join(root, "site", "tools", "text"), "indexer", "lessc"}},
+    }
+    for _, test := range tests {
+        tools := requiredTools(root, []config.Site{test.site})
+        want := append(append([]string{}, buildTools...), test.tools...)
+        if !reflect.DeepEqual(tools, want) {
+            t.Errorf("%s: tools %v, want %v", test.name, tools, want)
+        }
+    }
+}
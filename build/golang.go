@@ -0,0 +1,230 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "time"
+   "sync"
+   "errors"
+   "io/ioutil"
+   "strings"
+   "go/build"
+   "go/parser"
+   "go/token"
+   "path/filepath"
+   "github.com/GlenKelley/dev/command"
+)
+
+const commitVar = "main.commit"
+
+func (b *Builder) goCompiler(options Options) ProcessFile {
+    var lock sync.Mutex
+    built := map[string]bool{}
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        if strings.HasSuffix(path, "_test.go") { return nil }
+        isMain, err := isMainPackage(path)
+        if err != nil { return err }
+        if !isMain { return nil }
+
+        dir := filepath.Dir(path)
+        lock.Lock()
+        first := !built[dir]
+        built[dir] = true
+        lock.Unlock()
+        if !first { return nil }
+
+        return b.compileGoPackage(srcDir, buildDir, dir, options)
+    }
+}
+
+func (b *Builder) compileGoPackage(srcDir string, buildDir string, dir string, options Options) error {
+    modTime, err := latestModTime(dir, ".go")
+    if err != nil { return err }
+
+    destDir, err := replaceBasePath(srcDir, buildDir, dir)
+    if err != nil { return err }
+    err = MkdirAll(destDir)
+    if err != nil { return err }
+
+    browser, err := isBrowserPackage(dir)
+    if err != nil { return err }
+    if browser {
+        return b.compileWasmPackage(srcDir, dir, destDir, modTime, options)
+    }
+
+    workDir, pkg, err := goPackagePath(srcDir, dir, "", "")
+    if err != nil { return err }
+    cmd := command.New("go", append([]string{"vet"}, pkg...)...)
+    cmd.Dir = workDir
+    err = b.runCommand(cmd)
+    if err != nil { return err }
+
+    targets := options.GoTargets
+    if len(targets) == 0 {
+        targets = []string{""}
+    }
+    ldflags := "-X " + commitVar + "=" + options.Commit
+    buildFlags := goBuildFlags(options)
+    for _, target := range targets {
+        dest := filepath.Join(destDir, filepath.Base(dir))
+        env := os.Environ()
+        goos, goarch := "", ""
+        if target != "" {
+            parts := strings.SplitN(target, "/", 2)
+            if len(parts) != 2 { return fmt.Errorf("invalid go target %q", target) }
+            goos, goarch = parts[0], parts[1]
+            dest += "-" + goos + "-" + goarch
+            if goos == "windows" {
+                dest += ".exe"
+            }
+            env = append(env, "GOOS=" + goos, "GOARCH=" + goarch)
+        }
+        workDir, pkg, err := goPackagePath(srcDir, dir, goos, goarch)
+        if err != nil { return err }
+        args := append([]string{"build", "-ldflags", ldflags, "-o", dest}, buildFlags...)
+        cmd := command.New("go", append(args, pkg...)...)
+        cmd.Dir = workDir
+        cmd.Env = env
+        err = b.runCommand(cmd)
+        if err != nil { return err }
+
+        err = setFileTimestamp(dest, modTime)
+        if err != nil { return err }
+
+        err = os.Chmod(dest, executableMode)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+func (b *Builder) compileWasmPackage(srcDir string, dir string, destDir string, modTime time.Time, options Options) error {
+    workDir, pkg, err := goPackagePath(srcDir, dir, "js", "wasm")
+    if err != nil { return err }
+    env := append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+    cmd := command.New("go", append([]string{"vet"}, pkg...)...)
+    cmd.Dir = workDir
+    cmd.Env = env
+    err = b.runCommand(cmd)
+    if err != nil { return err }
+
+    dest := filepath.Join(destDir, filepath.Base(destDir) + ".wasm")
+    ldflags := "-X " + commitVar + "=" + options.Commit
+    args := append([]string{"build", "-ldflags", ldflags, "-o", dest}, goBuildFlags(options)...)
+    cmd = command.New("go", append(args, pkg...)...)
+    cmd.Dir = workDir
+    cmd.Env = env
+    err = b.runCommand(cmd)
+    if err != nil { return err }
+
+    b.compression.add(dest)
+
+    err = setFileTimestamp(dest, modTime)
+    if err != nil { return err }
+
+    err = os.Chmod(dest, dataMode)
+    if err != nil { return err }
+
+    return b.copyWasmExec(destDir)
+}
+
+func (b *Builder) copyWasmExec(destDir string) error {
+    bytes, err := command.Output(b.executor(), command.New("go", "env", "GOROOT"))
+    if err != nil { return err }
+    goroot := strings.TrimSpace(string(bytes))
+
+    for _, dir := range []string{"lib", "misc"} {
+        path := filepath.Join(goroot, dir, "wasm", "wasm_exec.js")
+        _, err := os.Stat(path)
+        if err != nil { continue }
+
+        dest := filepath.Join(destDir, "wasm_exec.js")
+        err = b.executor().Run(command.New("cp", path, dest))
+        if err != nil { return err }
+
+        b.compression.add(dest)
+
+        return os.Chmod(dest, dataMode)
+    }
+    return errors.New("wasm_exec.js not found in " + goroot)
+}
+
+func isBrowserPackage(dir string) (bool, error) {
+    wasm := build.Default
+    wasm.GOOS = "js"
+    wasm.GOARCH = "wasm"
+    infos, err := ioutil.ReadDir(dir)
+    if err != nil { return false, err }
+    for _, info := range infos {
+        name := info.Name()
+        if filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") { continue }
+        matchesHost, err := build.Default.MatchFile(dir, name)
+        if err != nil { return false, err }
+        matchesWasm, err := wasm.MatchFile(dir, name)
+        if err != nil { return false, err }
+        if matchesWasm && !matchesHost {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+func goBuildFlags(options Options) []string {
+    if options.Reproducible {
+        return []string{"-trimpath", "-buildvcs=false"}
+    }
+    return nil
+}
+
+func isMainPackage(path string) (bool, error) {
+    file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly)
+    if err != nil { return false, err }
+    return file.Name.Name == "main", nil
+}
+
+// goPackagePath returns the directory to run go in and the arguments naming
+// the package in dir. Inside a module that is the package's relative path;
+// outside one, where go refuses a package path, it is the package's non-test
+// files for goos and goarch (the host when empty).
+func goPackagePath(srcDir string, dir string, goos string, goarch string) (string, []string, error) {
+    for moduleRoot := dir; ; moduleRoot = filepath.Dir(moduleRoot) {
+        _, err := os.Stat(filepath.Join(moduleRoot, "go.mod"))
+        if err == nil {
+            relativePath, err := filepath.Rel(moduleRoot, dir)
+            if err != nil { return "", nil, err }
+            return moduleRoot, []string{"./" + filepath.ToSlash(relativePath)}, nil
+        }
+        if moduleRoot == srcDir || moduleRoot == filepath.Dir(moduleRoot) { break }
+    }
+
+    context := build.Default
+    if goos != "" {
+        context.GOOS = goos
+        context.GOARCH = goarch
+    }
+    files := []string{}
+    infos, err := ioutil.ReadDir(dir)
+    if err != nil { return "", nil, err }
+    for _, info := range infos {
+        name := info.Name()
+        if info.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") { continue }
+        matches, err := context.MatchFile(dir, name)
+        if err != nil { return "", nil, err }
+        if matches {
+            files = append(files, name)
+        }
+    }
+    if len(files) == 0 { return "", nil, fmt.Errorf("no go files in %s for %s/%s", dir, context.GOOS, context.GOARCH) }
+    return dir, files, nil
+}
+
+func latestModTime(dir string, ext string) (time.Time, error) {
+    latest := time.Time{}
+    infos, err := ioutil.ReadDir(dir)
+    if err != nil { return latest, err }
+    for _, info := range infos {
+        if filepath.Ext(info.Name()) == ext && info.ModTime().After(latest) {
+            latest = info.ModTime()
+        }
+    }
+    return latest, nil
+}
@@ -0,0 +1,491 @@
+package build
+
+import (
+   "bytes"
+   "fmt"
+   "os"
+   "time"
+   "sync"
+   "errors"
+   "io/ioutil"
+   "strings"
+   "html/template"
+   "path/filepath"
+   "encoding/json"
+   "github.com/GlenKelley/dev/collada"
+   "github.com/GlenKelley/dev/command"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/glsl"
+)
+
+type ProcessFile func(srcDir string, buildDir string, path string, info os.FileInfo) error
+
+func (b *Builder) builtinHandlers(options Options, shaders *ShaderBundle) map[string]ProcessFile {
+    compressed := []Step{b.minify, b.compress, setMode(dataMode)}
+    handlers := map[string]ProcessFile {
+        "less": b.pipeline(b.compileLess, compressed...),
+        "markdown": b.pipeline(b.compileMarkdown, compressed...),
+        "copy": b.pipeline(b.copy, setMode(dataMode)),
+        "copy-gzip": b.pipeline(b.copy, compressed...),
+        "coffee": b.pipeline(b.compileCoffeeScript, compressed...),
+        "coffeejson": b.pipeline(b.compileCoffeeJson, compressed...),
+        "go": b.goCompiler(options),
+        "shader": shaders.Compile(options),
+        "collada": b.colladaCompiler(options),
+        "ignore": ignore,
+    }
+    if len(options.Site.I18n.Locales) > 0 {
+        localizer := NewLocalizer(options.Site.I18n, b.siteURL())
+        localized := append(append([]Step{}, compressed...), b.recordSource)
+        handlers["markdown"] = localizer.Handler(b.renderMarkdown, localized...)
+        handlers["localize"] = localizer.Handler(compileHtmlTemplate, localized...)
+    }
+    return handlers
+}
+
+var defaultHandlers = map[string]string {
+    ".less": "less",
+    ".md": "markdown",
+    ".html": "copy-gzip",
+    ".css": "copy-gzip",
+    ".js": "copy-gzip",
+    ".jpg": "copy",
+    ".jpeg": "copy",
+    ".png": "copy",
+    ".gif": "copy",
+    ".woff": "copy",
+    ".ttf": "copy",
+    ".eot": "copy",
+    ".otf": "copy",
+    ".coffee": "coffee",
+    ".json": "copy-gzip",
+    ".svg": "copy-gzip",
+    ".go": "go",
+    ".coffeejson": "coffeejson",
+    ".fs": "shader",
+    ".vs": "shader",
+    ".glsl": "ignore",
+    ".dae": "collada",
+    ".DS_Store": "ignore",
+}
+
+// handlerNames maps extensions to the names of their handlers for a site,
+// given the extensions registered with a Builder.
+func handlerNames(site config.Site, registered map[string]string) map[string]string {
+    names := map[string]string{}
+    for ext, name := range defaultHandlers {
+        names[ext] = name
+    }
+    if len(site.I18n.Locales) > 0 {
+        names[".html"] = "localize"
+    }
+    for ext, name := range registered {
+        names[ext] = name
+    }
+    for name, p := range site.Plugins {
+        for _, ext := range p.Extensions {
+            names[ext] = name
+        }
+    }
+    for ext, name := range site.Handlers {
+        names[ext] = name
+    }
+    return names
+}
+
+func (b *Builder) fileHandlers(shaders *ShaderBundle, assets *AssetManifest) (map[string]ProcessFile, map[string]string, error) {
+    named := b.builtinHandlers(b.Options, shaders)
+    for name, handler := range b.handlers {
+        named[name] = handler
+    }
+    for name, stages := range b.Options.Site.Pipelines {
+        handler, err := b.configuredPipeline(name, stages, assets)
+        if err != nil { return nil, nil, err }
+        named[name] = handler
+    }
+    for name, p := range b.Options.Site.Plugins {
+        named[name] = b.pluginHandler(name, p)
+    }
+    names := handlerNames(b.Options.Site, b.extensions)
+    handlers := map[string]ProcessFile{}
+    for ext, name := range names {
+        handler, found := named[name]
+        if !found { return nil, nil, fmt.Errorf("unknown handler %q for %s", name, ext) }
+        handlers[ext] = handler
+    }
+    return handlers, names, nil
+}
+
+func ignore(srcDir string, buildDir string, path string, info os.FileInfo) error {
+    return nil
+}
+
+func (b *Builder) compileLess(srcDir string, buildDir string, path string) (string, error) {
+    base := filepath.Base(path)
+    isChild, err := filepath.Match("_*", base)
+    if err != nil || isChild { return "", err }
+
+    dest, err := replacePathAndExtention(srcDir, buildDir, path, ".css")
+    if err != nil { return "", err }
+
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+
+    cmd := command.New("lessc", path)
+    return dest, b.pipeCommandToFile(cmd, dest)
+}
+
+const layoutDir = "_layouts"
+const defaultLayout = "default"
+
+type Page struct {
+    Title string
+    Layout string
+    Date time.Time
+    Body string
+    FrontMatter bool
+}
+
+type PageContent struct {
+    Title string
+    Date time.Time
+    Content template.HTML
+}
+
+func (b *Builder) compileMarkdown(srcDir string, buildDir string, path string) (string, error) {
+    return b.renderMarkdown(srcDir, buildDir, path, nil)
+}
+
+// renderMarkdown renders a page into its layout. Markdown without front
+// matter, such as a README, is only a page when the site has the default
+// layout; otherwise it produces no output.
+func (b *Builder) renderMarkdown(srcDir string, buildDir string, path string, funcs template.FuncMap) (string, error) {
+    page, err := readPage(path)
+    if err != nil { return "", err }
+
+    layoutPath := filepath.Join(srcDir, layoutDir, page.Layout + ".html")
+    if !page.FrontMatter {
+        _, err = os.Stat(layoutPath)
+        if os.IsNotExist(err) { return "", nil }
+        if err != nil { return "", err }
+    }
+
+    dest, err := replacePathAndExtention(srcDir, buildDir, path, ".html")
+    if err != nil { return "", err }
+
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+
+    cmd := command.New("markdown")
+    cmd.Stdin = strings.NewReader(page.Body)
+    cmd.Stderr = os.Stdout
+    html, err := command.Output(b.executor(), cmd)
+    if err != nil { return "", err }
+
+    layout, err := template.New(filepath.Base(layoutPath)).Funcs(funcs).ParseFiles(layoutPath)
+    if err != nil { return "", err }
+
+    file, err := os.Create(dest)
+    if err != nil { return "", err }
+    defer file.Close()
+
+    content := PageContent{page.Title, page.Date, template.HTML(html)}
+    err = layout.Execute(file, content)
+    if err != nil { return "", err }
+    return dest, file.Close()
+}
+
+func readPage(path string) (Page, error) {
+    page := Page{Layout: defaultLayout}
+    bs, err := ioutil.ReadFile(path)
+    if err != nil { return page, err }
+    content := string(bs)
+
+    delimiter := "---\n"
+    if !strings.HasPrefix(content, delimiter) {
+        page.Body = content
+        return page, nil
+    }
+    page.FrontMatter = true
+    content = content[len(delimiter):]
+    end := strings.Index(content, "\n" + delimiter)
+    if end < 0 { return page, errors.New("unterminated front matter") }
+    header := content[:end]
+    page.Body = content[end + 1 + len(delimiter):]
+
+    for _, line := range strings.Split(header, "\n") {
+        parts := strings.SplitN(line, ":", 2)
+        if len(parts) != 2 { continue }
+        key := strings.TrimSpace(parts[0])
+        value := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
+        switch key {
+        case "title":
+            page.Title = value
+        case "layout":
+            page.Layout = value
+        case "date":
+            page.Date, err = parsePageDate(value)
+            if err != nil { return page, err }
+        }
+    }
+    return page, nil
+}
+
+func parsePageDate(value string) (time.Time, error) {
+    formats := []string { "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", time.RFC3339 }
+    for _, format := range formats {
+        t, err := time.Parse(format, value)
+        if err == nil { return t, nil }
+    }
+    return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+const shaderBundlePath = "shaders.json"
+
+type ShaderBundle struct {
+    lock sync.Mutex
+    shaders map[string]interface{}
+    modTime time.Time
+}
+
+func NewShaderBundle() *ShaderBundle {
+    return &ShaderBundle{shaders: map[string]interface{}{}}
+}
+
+func (b *ShaderBundle) Compile(options Options) ProcessFile {
+    defines := map[string]string {
+        "ENV_" + strings.ToUpper(identifierPattern.ReplaceAllString(options.Env, "_")): "1",
+    }
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        isPartial, err := filepath.Match("_*", filepath.Base(path))
+        if err != nil { return err }
+        if isPartial { return nil }
+
+        relativePath, err := filepath.Rel(srcDir, path)
+        if err != nil { return err }
+
+        source, err := glsl.Preprocess(path, defines)
+        if err != nil { return err }
+
+        b.lock.Lock()
+        defer b.lock.Unlock()
+        b.shaders[filepath.ToSlash(relativePath)] = source
+        if info.ModTime().After(b.modTime) {
+            b.modTime = info.ModTime()
+        }
+        return nil
+    }
+}
+
+func (b *ShaderBundle) Write(buildDir string, queue *compressionQueue) error {
+    if len(b.shaders) == 0 { return nil }
+    err := writeJson(b.shaders, buildDir, shaderBundlePath, queue)
+    if err != nil { return err }
+    return setFileTimestamp(filepath.Join(buildDir, shaderBundlePath), b.modTime)
+}
+
+func (b *Builder) colladaCompiler(options Options) ProcessFile {
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        if options.KeepModelSources {
+            err := b.pipeline(b.copy, setMode(dataMode))(srcDir, buildDir, path, info)
+            if err != nil { return err }
+        }
+        return b.pipeline(compileCollada, b.compress, setMode(dataMode))(srcDir, buildDir, path, info)
+    }
+}
+
+func compileCollada(srcDir string, buildDir string, path string) (string, error) {
+    dest, err := replacePathAndExtention(srcDir, buildDir, path, ".json")
+    if err != nil { return "", err }
+
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+
+    model, err := collada.Read(path)
+    if err != nil { return "", err }
+
+    file, err := os.Create(dest)
+    if err != nil { return "", err }
+    defer file.Close()
+
+    encoder := json.NewEncoder(file)
+    err = encoder.Encode(model)
+    if err != nil { return "", err }
+    return dest, file.Close()
+}
+
+func writeJson(content map[string]interface{}, buildDir string, path string, queue *compressionQueue) error {
+    dest := filepath.Join(buildDir, path)
+    
+    file, err := os.Create(dest)
+    if err != nil { return err }
+    defer file.Close()
+    
+    encoder := json.NewEncoder(file)
+    err = encoder.Encode(content)
+    if err != nil { return err }
+    file.Close()
+
+    queue.add(dest)
+
+    return os.Chmod(dest, dataMode)
+}
+
+func (b *Builder) compileCoffeeScript(srcDir string, buildDir string, path string) (string, error) {
+    dest, err := replacePathAndExtention(srcDir, buildDir, path, ".js")
+    if err != nil { return "", err }
+    
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+
+    cmd := command.New("coffee", "-p", path)
+    return dest, b.pipeCommandToFile(cmd, dest)
+}
+
+func (b *Builder) compileCoffeeJson(srcDir string, buildDir string, path string) (string, error) {
+    srcDir = filepath.Join(srcDir, "coffee")
+    buildDir = filepath.Join(buildDir, "js")
+    
+    dest, err := replacePathAndExtention(srcDir, buildDir, path, ".json")
+    if err != nil { return "", err }
+    
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+
+    cmd := command.New("coffee", "-p", "-b", path)
+    err = b.pipeCommandToFile(cmd, dest)
+    if err != nil { return "", err }
+
+    cmd = command.New("perl", "-pi", "-e", "s/;|\\(|\\)//g", dest)
+    err = b.executor().Run(cmd)
+    if err != nil { return "", err }
+    
+    cmd = command.New("perl", "-pi", "-e", "s/(\\w+):/\"$1\":/g", dest)
+    return dest, b.executor().Run(cmd)
+}
+
+// pipeCommandToFile runs cmd with its output written to path. Compiler
+// messages are included in the returned error so they can be reported with
+// the source that failed.
+func (b *Builder) pipeCommandToFile(cmd *command.Cmd, path string) error {
+    file, err := os.Create(path)
+    if err != nil { return err }
+    defer file.Close()
+    var stderr bytes.Buffer
+    cmd.Stdout = file
+    cmd.Stderr = &stderr
+    err = b.executor().Run(cmd)
+    if err != nil && stderr.Len() > 0 {
+        return fmt.Errorf("%s\n%s", err, strings.TrimSpace(stderr.String()))
+    }
+    os.Stdout.Write(stderr.Bytes())
+    return err
+}
+
+func replaceBasePath(srcDir string, buildDir string, path string) (string, error) {
+    relativePath, err := filepath.Rel(srcDir, path)
+    if err != nil { return "", err }
+    return filepath.Join(buildDir, relativePath), nil
+}
+
+func replaceExtention(path string, ext string) string {
+    dir := filepath.Dir(path)
+    name := nameWithoutExt(path)
+    return filepath.Join(dir, name + ext)
+}
+
+func replacePathAndExtention(srcDir string, buildDir string, path string, ext string) (string, error) {
+    path, err := replaceBasePath(srcDir, buildDir, path)
+    if err != nil { return "", err }
+    
+    return replaceExtention(path, ext), nil
+}
+
+func (b *Builder) copy(srcDir string, buildDir string, path string) (string, error) {
+    relativePath, err := filepath.Rel(srcDir, path)
+    if err != nil { return "", err }
+    
+    dest := filepath.Join(buildDir, relativePath)
+    dir := filepath.Dir(dest)
+    err = MkdirAll(dir)
+    if err != nil { return "", err }
+    
+    cmd := command.New("cp", path, dest)
+    err = b.executor().Run(cmd)
+    if err != nil { return "", err }
+    b.sources.add(dest, path, true)
+    return dest, nil
+}
+
+func MkdirAll(path string) error {
+    return os.MkdirAll(path, 0755)
+}
+
+func nameWithoutExt(path string) string {
+    filename := filepath.Base(path)
+    ext := filepath.Ext(path)
+    return filename[0:len(filename)-len(ext)]
+}
+
+func (b *Builder) minifyJs(jarDir, src, dest string) error {
+    jarPath := filepath.Join(jarDir, "compiler.jar")
+    cmd := command.New("java", "-jar", jarPath, "--js", src, "--js_output_file", dest, "--compilation_level", "SIMPLE_OPTIMIZATIONS")
+    return b.executor().Run(cmd)
+}
+
+// compressionQueue holds the outputs of one build to gzip once every step
+// that reads them has run.
+type compressionQueue struct {
+    lock sync.Mutex
+    paths []string
+}
+
+func (q *compressionQueue) add(path string) {
+    q.lock.Lock()
+    defer q.lock.Unlock()
+    q.paths = append(q.paths, path)
+}
+
+// compressPending gzips the files queued by the current build, unless
+// compression is disabled for the build.
+func (b *Builder) compressPending() error {
+    queue := b.compression
+    queue.lock.Lock()
+    defer queue.lock.Unlock()
+    if b.Options.NoCompress { return nil }
+    for _, path := range queue.paths {
+        info, err := os.Stat(path)
+        if err != nil { return err }
+
+        err = b.gZipFile(path)
+        if err != nil { return err }
+
+        err = setFileTimestamp(path, info.ModTime())
+        if err != nil { return err }
+
+        err = os.Chmod(path, info.Mode())
+        if err != nil { return err }
+    }
+    return nil
+}
+
+func (b *Builder) gZipFile(path string) error {
+    err := b.executor().Run(command.New("gzip", "-n", path))
+    if err != nil { return err }
+    return os.Rename(path + ".gz", path)
+}
+
+func setFileTimestamp(path string, time time.Time) error {
+    return os.Chtimes(path, time, time)
+}
+
+func (b *Builder) runCommand(cmd *command.Cmd) error {
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stdout
+    return b.executor().Run(cmd)
+}
@@ -0,0 +1,221 @@
+package build
+
+import (
+    "errors"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+    "github.com/GlenKelley/dev/command"
+)
+
+// fakeTools stands in for cp and the compilers, writing output where the
+// real ones would.
+func fakeTools(c *command.Cmd) error {
+    switch c.Name {
+    case "lessc":
+        _, err := io.WriteString(c.Stdout, "a { color: red; }\n")
+        return err
+    case "coffee":
+        _, err := io.WriteString(c.Stdout, "var x = 1;\n")
+        return err
+    case "cp":
+        content, err := ioutil.ReadFile(c.Args[0])
+        if err != nil { return err }
+        return ioutil.WriteFile(c.Args[1], content, 0644)
+    case "go":
+        for i, arg := range c.Args {
+            if arg == "-o" {
+                return ioutil.WriteFile(c.Args[i+1], []byte("binary"), 0644)
+            }
+        }
+    }
+    return nil
+}
+
+func TestHandlers(t *testing.T) {
+    tests := []struct {
+        name string
+        handler string
+        options Options
+        source string
+        content string
+        commands []string
+        outputs map[string]string
+        mode os.FileMode
+    }{
+        {"copy", "copy", Options{}, "img/a.png", "png",
+            []string{"cp $SRC/img/a.png $OUT/img/a.png"},
+            map[string]string{"img/a.png": "png"}, dataMode},
+        {"copy gzip", "copy-gzip", Options{}, "a.css", "a{}",
+            []string{"cp $SRC/a.css $OUT/a.css"},
+            map[string]string{"a.css": "a{}"}, dataMode},
+        {"less", "less", Options{}, "css/site.less", "@c: red;",
+            []string{"lessc $SRC/css/site.less"},
+            map[string]string{"css/site.css": "a { color: red; }\n"}, dataMode},
+        {"less partial", "less", Options{}, "css/_mixins.less", "", nil, map[string]string{}, dataMode},
+        {"markdown without layout", "markdown", Options{}, "README.md", "# Readme\n", nil, map[string]string{}, dataMode},
+        {"coffee", "coffee", Options{}, "coffee/app.coffee", "x = 1",
+            []string{"coffee -p $SRC/coffee/app.coffee"},
+            map[string]string{"coffee/app.js": "var x = 1;\n"}, dataMode},
+        {"go without go.mod", "go", Options{Commit: "abc"}, "cmd/tool/main.go", "package main\n",
+            []string{"go vet main.go", "go build -ldflags -X main.commit=abc -o $OUT/cmd/tool/tool main.go"},
+            map[string]string{"cmd/tool/tool": "binary"}, executableMode},
+        {"go targets", "go", Options{Commit: "abc", GoTargets: []string{"linux/arm64"}}, "cmd/tool/main.go", "package main\n",
+            []string{"go vet main.go", "go build -ldflags -X main.commit=abc -o $OUT/cmd/tool/tool-linux-arm64 main.go"},
+            map[string]string{"cmd/tool/tool-linux-arm64": "binary"}, executableMode},
+        {"go library", "go", Options{}, "lib/lib.go", "package lib\n", nil, map[string]string{}, dataMode},
+    }
+    for _, test := range tests {
+        root, err := ioutil.TempDir("", "handler-test")
+        if err != nil { t.Fatal(err) }
+        defer os.RemoveAll(root)
+        srcDir := filepath.Join(root, "src")
+        buildDir := filepath.Join(root, "out")
+        path := filepath.Join(srcDir, filepath.FromSlash(test.source))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        err = ioutil.WriteFile(path, []byte(test.content), 0600)
+        if err != nil { t.Fatal(err) }
+        info, err := os.Stat(path)
+        if err != nil { t.Fatal(err) }
+
+        recorder := &command.Recorder{Respond: fakeTools}
+        test.options.Executor = recorder
+        b := NewBuilder(test.options)
+        handler := b.builtinHandlers(b.Options, NewShaderBundle())[test.handler]
+        err = handler(srcDir, buildDir, path, info)
+        if err != nil {
+            t.Errorf("%s: %s", test.name, err)
+            continue
+        }
+
+        commands := []string{}
+        for _, c := range recorder.Commands() {
+            commands = append(commands, strings.NewReplacer(srcDir, "$SRC", buildDir, "$OUT").Replace(c))
+        }
+        if !reflect.DeepEqual(commands, append([]string{}, test.commands...)) {
+            t.Errorf("%s: ran %q, want %q", test.name, commands, test.commands)
+        }
+
+        outputs := map[string]string{}
+        sourceTime := info.ModTime()
+        filepath.Walk(buildDir, func (file string, info os.FileInfo, err error) error {
+            if err != nil || info.IsDir() { return err }
+            relativePath, _ := filepath.Rel(buildDir, file)
+            content, _ := ioutil.ReadFile(file)
+            outputs[filepath.ToSlash(relativePath)] = string(content)
+            if info.Mode() != test.mode {
+                t.Errorf("%s: %s has mode %s, want %s", test.name, relativePath, info.Mode(), test.mode)
+            }
+            if !info.ModTime().Equal(sourceTime) {
+                t.Errorf("%s: %s has time %s, want the source's", test.name, relativePath, info.ModTime())
+            }
+            return nil
+        })
+        if !reflect.DeepEqual(outputs, test.outputs) {
+            t.Errorf("%s: wrote %q, want %q", test.name, outputs, test.outputs)
+        }
+    }
+}
+
+func TestReadPage(t *testing.T) {
+    date := time.Date(2014, 3, 1, 9, 30, 0, 0, time.UTC)
+    tests := []struct {
+        content string
+        page Page
+        err string
+    }{
+        {"# Readme\n", Page{Layout: "default", Body: "# Readme\n"}, ""},
+        {"---\ntitle: \"Hello: world\"\nlayout: post\ndate: 2014-03-01 09:30\n---\nBody\n",
+            Page{Title: "Hello: world", Layout: "post", Date: date, Body: "Body\n", FrontMatter: true}, ""},
+        {"---\nignored\n---\n", Page{Layout: "default", Body: "", FrontMatter: true}, ""},
+        {"---\ntitle: a\n", Page{}, "unterminated front matter"},
+        {"---\ndate: March\n---\n", Page{}, `invalid date "March"`},
+    }
+    dir, err := ioutil.TempDir("", "page-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(dir)
+    path := filepath.Join(dir, "page.md")
+    for _, test := range tests {
+        err = ioutil.WriteFile(path, []byte(test.content), 0644)
+        if err != nil { t.Fatal(err) }
+        page, err := readPage(path)
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err || (err == nil && !reflect.DeepEqual(page, test.page)) {
+            t.Errorf("readPage(%q) = %+v, %q, want %+v, %q", test.content, page, message, test.page, test.err)
+        }
+    }
+}
+
+func TestHandlerCommandFailure(t *testing.T) {
+    root, err := ioutil.TempDir("", "handler-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(root)
+    path := filepath.Join(root, "a.less")
+    err = ioutil.WriteFile(path, []byte("a {"), 0644)
+    if err != nil { t.Fatal(err) }
+    info, err := os.Stat(path)
+    if err != nil { t.Fatal(err) }
+
+    recorder := &command.Recorder{Respond: func(c *command.Cmd) error {
+        io.WriteString(c.Stderr, "ParseError: missing closing `}`")
+        return errors.New("exit status 1")
+    }}
+    b := NewBuilder(Options{Executor: recorder})
+    err = b.builtinHandlers(b.Options, NewShaderBundle())["less"](root, filepath.Join(root, "out"), path, info)
+    if err == nil || err.Error() != "exit status 1\nParseError: missing closing `}`" {
+        t.Errorf("error %v, want the compiler's message", err)
+    }
+}
+
+func TestGoPackagePath(t *testing.T) {
+    root, err := ioutil.TempDir("", "gopackage-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(root)
+    files := map[string]string{
+        "mod/go.mod": "module example.com/mod\n",
+        "mod/cmd/tool/main.go": "package main\n",
+        "plain/main.go": "package main\n",
+        "plain/main_test.go": "package main\n",
+        "plain/open_windows.go": "package main\n",
+        "plain/open_other.go": "//go:build !windows\n\npackage main\n",
+        "plain/data.txt": "",
+    }
+    for name, content := range files {
+        path := filepath.Join(root, filepath.FromSlash(name))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        err = ioutil.WriteFile(path, []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+    }
+
+    tests := []struct {
+        dir string
+        goos string
+        workDir string
+        pkg []string
+    }{
+        {"mod/cmd/tool", "", "mod", []string{"./cmd/tool"}},
+        {"mod/cmd/tool", "windows", "mod", []string{"./cmd/tool"}},
+        {"plain", "linux", "plain", []string{"main.go", "open_other.go"}},
+        {"plain", "windows", "plain", []string{"main.go", "open_windows.go"}},
+    }
+    for _, test := range tests {
+        workDir, pkg, err := goPackagePath(root, filepath.Join(root, test.dir), test.goos, "amd64")
+        if err != nil {
+            t.Errorf("%s %s: %s", test.dir, test.goos, err)
+            continue
+        }
+        if workDir != filepath.Join(root, test.workDir) || !reflect.DeepEqual(pkg, test.pkg) {
+            t.Errorf("%s %s: %s %q, want %s %q", test.dir, test.goos, workDir, pkg, test.workDir, test.pkg)
+        }
+    }
+}
@@ -0,0 +1,170 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "path"
+   "sync"
+   "bytes"
+   "regexp"
+   "strings"
+   "io/ioutil"
+   "html/template"
+   "path/filepath"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/i18n"
+   "github.com/GlenKelley/dev/s3"
+)
+
+const localeDir = "_locales"
+
+var rootLinkPattern = regexp.MustCompile(`(?i)(\shref\s*=\s*["']?)/([^"'\s>]*)`)
+
+// LocalizedCompile renders a page with template functions for one locale,
+// "t" to translate a message key and "locale" for the locale name.
+type LocalizedCompile func(srcDir string, buildDir string, path string, funcs template.FuncMap) (string, error)
+
+// Localizer renders pages once per locale into /<locale>/ trees, using the
+// string tables in _locales, and links the translations with hreflang. Keys
+// missing from the default locale's table render as the key itself; any
+// other locale missing a key fails the page. Root-absolute links to pages,
+// like href="/about", are moved into the page's tree.
+type Localizer struct {
+    options config.I18n
+    siteURL string
+    once sync.Once
+    tables map[string]i18n.Table
+    err error
+}
+
+func NewLocalizer(options config.I18n, siteURL string) *Localizer {
+    if options.Default == "" {
+        options.Default = options.Locales[0]
+    }
+    return &Localizer{options: options, siteURL: siteURL}
+}
+
+func (l *Localizer) load(srcDir string) error {
+    l.once.Do(func() {
+        l.tables = map[string]i18n.Table{}
+        for _, locale := range l.options.Locales {
+            l.tables[locale], l.err = i18n.Load(filepath.Join(srcDir, localeDir), locale)
+            if l.err != nil { return }
+        }
+    })
+    return l.err
+}
+
+func (l *Localizer) Handler(compile LocalizedCompile, steps ...Step) ProcessFile {
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        err := l.load(srcDir)
+        if err != nil { return err }
+
+        dests := []string{}
+        pagePath := ""
+        missing := []string{}
+        for _, locale := range l.options.Locales {
+            locale := locale
+            translator := i18n.NewTranslator(locale, l.tables[locale])
+            funcs := template.FuncMap {
+                "t": translator.Translate,
+                "locale": func() string { return locale },
+            }
+            treeDir := filepath.Join(buildDir, locale)
+            dest, err := compile(srcDir, treeDir, path, funcs)
+            if err != nil { return err }
+            if dest == "" { return nil }
+            pagePath, err = filepath.Rel(treeDir, dest)
+            if err != nil { return err }
+            err = l.localizeLinks(dest, locale)
+            if err != nil { return err }
+            dests = append(dests, dest)
+            if locale == l.options.Default { continue }
+            for _, key := range translator.Missing() {
+                missing = append(missing, locale + ":" + key)
+            }
+        }
+        if len(missing) > 0 {
+            return fmt.Errorf("missing translations %s", strings.Join(missing, ", "))
+        }
+
+        alternates := l.alternates(pagePath)
+        for _, dest := range dests {
+            err = addAlternates(dest, alternates)
+            if err != nil { return err }
+            err = runSteps(&Artifact{path, dest, info.ModTime()}, steps)
+            if err != nil { return err }
+        }
+        return nil
+    }
+}
+
+func (l *Localizer) alternates(pagePath string) string {
+    item := filepath.ToSlash(s3.ItemPath(pagePath))
+    var links bytes.Buffer
+    for _, locale := range l.options.Locales {
+        fmt.Fprintf(&links, "<link rel=\"alternate\" hreflang=\"%s\" href=\"%s/%s/%s\">\n", locale, l.siteURL, locale, item)
+    }
+    fmt.Fprintf(&links, "<link rel=\"alternate\" hreflang=\"x-default\" href=\"%s/%s/%s\">\n", l.siteURL, l.options.Default, item)
+    return links.String()
+}
+
+// localizeLinks prefixes the root-absolute links to pages in path with
+// /<locale>. Links to assets, which are not localized, and links already in
+// a locale's tree are left alone.
+func (l *Localizer) localizeLinks(path string, locale string) error {
+    page, err := ioutil.ReadFile(path)
+    if err != nil { return err }
+    localized := rootLinkPattern.ReplaceAllFunc(page, func(link []byte) []byte {
+        match := rootLinkPattern.FindSubmatch(link)
+        url := string(match[2])
+        if !l.isPageLink(url) { return link }
+        return []byte(string(match[1]) + "/" + locale + "/" + url)
+    })
+    if bytes.Equal(localized, page) { return nil }
+    return ioutil.WriteFile(path, localized, dataMode)
+}
+
+// isPageLink reports whether url, a root-absolute path without its leading
+// slash, names a page outside the locale trees.
+func (l *Localizer) isPageLink(url string) bool {
+    if strings.HasPrefix(url, "/") { return false }
+    url = strings.SplitN(strings.SplitN(url, "#", 2)[0], "?", 2)[0]
+    first := strings.SplitN(url, "/", 2)[0]
+    for _, locale := range l.options.Locales {
+        if first == locale { return false }
+    }
+    ext := path.Ext(url)
+    return strings.HasSuffix(url, "/") || ext == "" || ext == ".html"
+}
+
+// addAlternates inserts the hreflang links before the page's </head>. Pages
+// without a head are left alone.
+func addAlternates(path string, links string) error {
+    page, err := ioutil.ReadFile(path)
+    if err != nil { return err }
+    end := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+    if end < 0 { return nil }
+    annotated := append(append(append([]byte{}, page[:end]...), links...), page[end:]...)
+    return ioutil.WriteFile(path, annotated, dataMode)
+}
+
+// compileHtmlTemplate renders an HTML source as an html/template.
+func compileHtmlTemplate(srcDir string, buildDir string, path string, funcs template.FuncMap) (string, error) {
+    dest, err := replaceBasePath(srcDir, buildDir, path)
+    if err != nil { return "", err }
+
+    err = MkdirAll(filepath.Dir(dest))
+    if err != nil { return "", err }
+
+    page, err := template.New(filepath.Base(path)).Funcs(funcs).ParseFiles(path)
+    if err != nil { return "", err }
+
+    file, err := os.Create(dest)
+    if err != nil { return "", err }
+    defer file.Close()
+
+    err = page.Execute(file, nil)
+    if err != nil { return "", err }
+    return dest, file.Close()
+}
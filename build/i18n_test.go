@@ -0,0 +1,118 @@
+package build
+
+import (
+    "html/template"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "github.com/GlenKelley/dev/command"
+    "github.com/GlenKelley/dev/config"
+    "github.com/GlenKelley/dev/sri"
+)
+
+func TestLocalizerMissingKeys(t *testing.T) {
+    srcDir, err := ioutil.TempDir("", "i18n-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(srcDir)
+    buildDir := filepath.Join(srcDir, "out")
+    err = os.MkdirAll(filepath.Join(srcDir, localeDir), 0755)
+    if err != nil { t.Fatal(err) }
+    tables := map[string]string{
+        "en.json": `{"title": "Welcome"}`,
+        "de.yaml": "title: Willkommen\nnav:\n  about: \"Über uns\"\n",
+    }
+    for name, content := range tables {
+        err = ioutil.WriteFile(filepath.Join(srcDir, localeDir, name), []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+    }
+    page := filepath.Join(srcDir, "index.html")
+    err = ioutil.WriteFile(page, nil, 0644)
+    if err != nil { t.Fatal(err) }
+    info, err := os.Stat(page)
+    if err != nil { t.Fatal(err) }
+
+    tests := []struct {
+        name string
+        options config.I18n
+        keys []string
+        err string
+    }{
+        {"all present", config.I18n{Locales: []string{"en", "de"}}, []string{"title"}, ""},
+        {"missing from default", config.I18n{Locales: []string{"en", "de"}}, []string{"nav.about"}, ""},
+        {"missing from other", config.I18n{Default: "de", Locales: []string{"en", "de"}}, []string{"nav.about"}, "missing translations en:nav.about"},
+        {"missing from all", config.I18n{Locales: []string{"en", "de"}}, []string{"nav.home"}, "missing translations de:nav.home"},
+    }
+    for _, test := range tests {
+        compile := func(srcDir string, buildDir string, path string, funcs template.FuncMap) (string, error) {
+            translate := funcs["t"].(func(string) string)
+            for _, key := range test.keys {
+                translate(key)
+            }
+            dest := filepath.Join(buildDir, "index.html")
+            err := MkdirAll(buildDir)
+            if err != nil { return "", err }
+            return dest, ioutil.WriteFile(dest, nil, 0644)
+        }
+        handler := NewLocalizer(test.options, "http://example.com").Handler(compile)
+        err := handler(srcDir, buildDir, page, info)
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err {
+            t.Errorf("%s: error %q, want %q", test.name, message, test.err)
+        }
+    }
+}
+
+func TestLocalizedTree(t *testing.T) {
+    root, err := ioutil.TempDir("", "i18n-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(root)
+    srcDir := filepath.Join(root, "src")
+    buildDir := filepath.Join(root, "out")
+    files := map[string]string{
+        "_locales/en.json": `{"about": "About"}`,
+        "_locales/de.json": `{"about": "Über uns"}`,
+        "index.html": `<a href="/about">{{t "about"}}</a> <a href="/{{locale}}/about">x</a>`,
+        "about.html": `<link rel="stylesheet" href="/css/site.css"><a href='/#top'>home</a> <a href="/about?q=1">self</a>`,
+        "css/site.css": `a { color: red; }`,
+    }
+    for name, content := range files {
+        path := filepath.Join(srcDir, filepath.FromSlash(name))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        err = ioutil.WriteFile(path, []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+    }
+
+    site := config.Site{I18n: config.I18n{Locales: []string{"en", "de"}}}
+    b := NewBuilder(Options{Site: site, NoCompress: true, Executor: &command.Recorder{Respond: fakeTools}})
+    _, err = b.Build(srcDir, buildDir)
+    if err != nil { t.Fatal(err) }
+
+    integrity := ` integrity="` + sri.Digest([]byte(files["css/site.css"])) + `" crossorigin="anonymous"`
+    want := map[string]string{
+        "en/index.html": `<a href="/en/about">About</a> <a href="/en/about">x</a>`,
+        "de/index.html": `<a href="/de/about">Über uns</a> <a href="/de/about">x</a>`,
+        "en/about.html": `<link rel="stylesheet" href="/css/site.css"` + integrity + `><a href='/en/#top'>home</a> <a href="/en/about?q=1">self</a>`,
+        "de/about.html": `<link rel="stylesheet" href="/css/site.css"` + integrity + `><a href='/de/#top'>home</a> <a href="/de/about?q=1">self</a>`,
+        "css/site.css": files["css/site.css"],
+    }
+    outputs := map[string]string{}
+    err = filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil || info.IsDir() { return err }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        content, err := ioutil.ReadFile(path)
+        outputs[filepath.ToSlash(relativePath)] = string(content)
+        return err
+    })
+    if err != nil { t.Fatal(err) }
+    for name, content := range want {
+        if outputs[name] != content {
+            t.Errorf("%s = %q, want %q", name, outputs[name], content)
+        }
+    }
+}
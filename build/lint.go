@@ -0,0 +1,106 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "sort"
+   "strings"
+   "io/ioutil"
+   "path/filepath"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/lint"
+   "github.com/GlenKelley/dev/plugin"
+)
+
+const lintOff = "off"
+
+// lintOutputs runs the lint checks over the build outputs, printing each
+// problem and failing if any check configured as an error found one.
+func lintOutputs(srcDir string, buildDir string, options config.Lint) error {
+    severities := map[string]string{}
+    names := []string{}
+    for name := range lint.Checks {
+        severity := options.Checks[name]
+        if severity == "" {
+            severity = plugin.Error
+        }
+        if severity != plugin.Error && severity != plugin.Warning && severity != lintOff {
+            return fmt.Errorf("invalid severity %q for lint check %s", severity, name)
+        }
+        severities[name] = severity
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for name := range options.Checks {
+        if _, found := lint.Checks[name]; !found { return fmt.Errorf("unknown lint check %s", name) }
+    }
+    globs := []string{}
+    for glob := range options.Schemas {
+        globs = append(globs, glob)
+    }
+    sort.Strings(globs)
+
+    errors := 0
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() { return nil }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        file := filepath.ToSlash(relativePath)
+
+        var content []byte
+        for _, name := range names {
+            if severities[name] == lintOff || !hasExtension(lint.Checks[name], filepath.Ext(path)) { continue }
+            if content == nil {
+                content, err = readBuildFile(path)
+                if err != nil { return err }
+            }
+            problems := []lint.Problem{}
+            switch name {
+            case "json":
+                problems = lint.JSON(content)
+            case "schema":
+                schema := schemaFor(file, globs, options.Schemas)
+                if schema == "" { continue }
+                bs, err := ioutil.ReadFile(filepath.Join(srcDir, filepath.FromSlash(schema)))
+                if err != nil { return err }
+                problems = lint.Schema(content, bs)
+            case "html":
+                problems = lint.HTML(content)
+            case "css":
+                problems = lint.CSS(content)
+            case "svg":
+                problems = lint.SVG(content)
+            }
+            for _, problem := range problems {
+                diagnostic := plugin.Diagnostic{Severity: severities[name], File: file, Line: problem.Line, Message: problem.Message}
+                fmt.Println(diagnostic)
+                if severities[name] == plugin.Error {
+                    errors++
+                }
+            }
+        }
+        return nil
+    })
+    if err != nil { return err }
+    if errors > 0 {
+        return fmt.Errorf("%d lint errors", errors)
+    }
+    return nil
+}
+
+func hasExtension(exts []string, ext string) bool {
+    for _, e := range exts {
+        if strings.EqualFold(e, ext) { return true }
+    }
+    return false
+}
+
+func schemaFor(file string, globs []string, schemas map[string]string) string {
+    for _, glob := range globs {
+        if matchGlob(strings.Split(glob, "/"), strings.Split(file, "/")) {
+            return schemas[glob]
+        }
+    }
+    return ""
+}
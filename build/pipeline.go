@@ -0,0 +1,195 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "sync"
+   "time"
+   "strings"
+   "io/ioutil"
+   "path/filepath"
+   "crypto/sha256"
+)
+
+// Modes for files written to the build directory. Only compiled programs are
+// executable.
+const dataMode = os.FileMode(0644)
+const executableMode = os.FileMode(0755)
+
+// Compile writes the output for a single source file and returns where it was
+// written, or an empty path if the source produces no output.
+type Compile func(srcDir string, buildDir string, path string) (string, error)
+
+// Artifact is an output file moving through a pipeline.
+type Artifact struct {
+    Source string
+    Path string
+    ModTime time.Time
+}
+
+// Step transforms an artifact in place. Steps may rename the artifact by
+// updating its Path.
+type Step func(a *Artifact) error
+
+func (b *Builder) pipeline(compile Compile, steps ...Step) ProcessFile {
+    steps = append(append([]Step{}, steps...), b.recordSource)
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        dest, err := compile(srcDir, buildDir, path)
+        if err != nil { return err }
+        if dest == "" { return nil }
+        return runSteps(&Artifact{path, dest, info.ModTime()}, steps)
+    }
+}
+
+func runSteps(a *Artifact, steps []Step) error {
+    for _, step := range steps {
+        err := step(a)
+        if err != nil { return err }
+    }
+    return setFileTimestamp(a.Path, a.ModTime)
+}
+
+// minify runs javascript through the closure compiler when the build was
+// given a compiler directory.
+func (b *Builder) minify(a *Artifact) error {
+    if b.Options.ClosureDir == "" || filepath.Ext(a.Path) != ".js" { return nil }
+    tmp := a.Path + ".min"
+    err := b.minifyJs(b.Options.ClosureDir, a.Path, tmp)
+    if err != nil { return err }
+    return os.Rename(tmp, a.Path)
+}
+
+func (b *Builder) compress(a *Artifact) error {
+    b.compression.add(a.Path)
+    return nil
+}
+
+// recordSource remembers the artifact's source for checks that run on the
+// build output.
+func (b *Builder) recordSource(a *Artifact) error {
+    if a.Source != "" {
+        b.sources.add(a.Path, a.Source, false)
+    }
+    return nil
+}
+
+// sourceMap holds the source each output of one build was made from.
+type sourceMap struct {
+    lock sync.Mutex
+    sources map[string]outputSource
+}
+
+// outputSource is the source of an output. SameLines is set for copies, whose
+// lines are the source's lines.
+type outputSource struct {
+    Path string
+    SameLines bool
+}
+
+// add records the source of output unless it is already known, so a copy
+// stays marked as one as it passes through later steps.
+func (m *sourceMap) add(output string, source string, sameLines bool) {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+    if m.sources == nil {
+        m.sources = map[string]outputSource{}
+    }
+    if _, found := m.sources[output]; found { return }
+    m.sources[output] = outputSource{source, sameLines}
+}
+
+func (m *sourceMap) source(output string) (outputSource, bool) {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+    source, found := m.sources[output]
+    return source, found
+}
+
+func setMode(mode os.FileMode) Step {
+    return func(a *Artifact) error {
+        return os.Chmod(a.Path, mode)
+    }
+}
+
+const assetManifestPath = "assets.json"
+
+// AssetManifest maps the build paths of fingerprinted files to their
+// content addressed names.
+type AssetManifest struct {
+    lock sync.Mutex
+    buildDir string
+    assets map[string]interface{}
+}
+
+func NewAssetManifest(buildDir string) *AssetManifest {
+    return &AssetManifest{buildDir: buildDir, assets: map[string]interface{}{}}
+}
+
+// Fingerprint renames an artifact to include a hash of its content, so it can
+// be cached indefinitely. It must run before the artifact is compressed.
+func (m *AssetManifest) Fingerprint(a *Artifact) error {
+    bs, err := ioutil.ReadFile(a.Path)
+    if err != nil { return err }
+    hash := fmt.Sprintf("%x", sha256.Sum256(bs))[:10]
+    ext := filepath.Ext(a.Path)
+    dest := strings.TrimSuffix(a.Path, ext) + "-" + hash + ext
+    err = os.Rename(a.Path, dest)
+    if err != nil { return err }
+
+    from, err := filepath.Rel(m.buildDir, a.Path)
+    if err != nil { return err }
+    to, err := filepath.Rel(m.buildDir, dest)
+    if err != nil { return err }
+    m.lock.Lock()
+    defer m.lock.Unlock()
+    m.assets[filepath.ToSlash(from)] = filepath.ToSlash(to)
+    a.Path = dest
+    return nil
+}
+
+func (m *AssetManifest) Write(queue *compressionQueue) error {
+    if len(m.assets) == 0 { return nil }
+    return writeJson(m.assets, m.buildDir, assetManifestPath, queue)
+}
+
+func (b *Builder) compilers() map[string]Compile {
+    return map[string]Compile {
+        "copy": b.copy,
+        "less": b.compileLess,
+        "coffee": b.compileCoffeeScript,
+        "coffeejson": b.compileCoffeeJson,
+        "markdown": b.compileMarkdown,
+        "collada": compileCollada,
+    }
+}
+
+func (b *Builder) steps(assets *AssetManifest) map[string]Step {
+    return map[string]Step {
+        "minify": b.minify,
+        "fingerprint": assets.Fingerprint,
+        "compress": b.compress,
+        "set-mode": setMode(dataMode),
+    }
+}
+
+// configuredPipeline builds a handler from a site config pipeline, a compile
+// step name followed by step names, e.g. ["copy", "minify", "compress"].
+func (b *Builder) configuredPipeline(name string, stages []string, assets *AssetManifest) (ProcessFile, error) {
+    if len(stages) == 0 { return nil, fmt.Errorf("pipeline %s is empty", name) }
+    compile, found := b.compilers()[stages[0]]
+    if !found { return nil, fmt.Errorf("pipeline %s: unknown compile step %q", name, stages[0]) }
+
+    named := b.steps(assets)
+    steps := []Step{}
+    compressed := false
+    for _, stage := range stages[1:] {
+        step, found := named[stage]
+        if !found { return nil, fmt.Errorf("pipeline %s: unknown step %q", name, stage) }
+        if stage == "fingerprint" && compressed {
+            return nil, fmt.Errorf("pipeline %s: fingerprint must come before compress", name)
+        }
+        compressed = compressed || stage == "compress"
+        steps = append(steps, step)
+    }
+    return b.pipeline(compile, steps...), nil
+}
@@ -0,0 +1,102 @@
+package build
+
+import (
+   "bytes"
+   "encoding/json"
+   "fmt"
+   "os"
+   "time"
+   "path/filepath"
+   "strings"
+   "github.com/GlenKelley/dev/command"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/plugin"
+)
+
+func (b *Builder) pluginHandler(name string, p config.Plugin) ProcessFile {
+    return func(srcDir string, buildDir string, path string, info os.FileInfo) error {
+        request := plugin.Request{
+            Version: plugin.Version,
+            Source: path,
+            SourceRoot: srcDir,
+            OutputRoot: buildDir,
+            Env: b.Options.Env,
+            Options: p.Options,
+        }
+        files, err := b.runPlugin(name, p, request)
+        if err != nil { return err }
+        return b.finishOutputs(name, buildDir, path, files, info.ModTime())
+    }
+}
+
+func (b *Builder) pluginAggregate(name string, p config.Plugin) Aggregate {
+    run := func(srcDir string, buildDir string, sources []string, outputs []string) ([]plugin.File, error) {
+        request := plugin.Request{
+            Version: plugin.Version,
+            Sources: sources,
+            Outputs: outputs,
+            SourceRoot: srcDir,
+            OutputRoot: buildDir,
+            Env: b.Options.Env,
+            Options: p.Options,
+        }
+        return b.runPlugin(name, p, request)
+    }
+    return Aggregate{name, p.Sources, p.Outputs, run}
+}
+
+func (b *Builder) runPlugin(name string, p config.Plugin, request plugin.Request) ([]plugin.File, error) {
+    input, err := json.Marshal(request)
+    if err != nil { return nil, err }
+
+    executable := pluginCommand(request.SourceRoot, p.Command)
+    var output bytes.Buffer
+    cmd := command.New(executable, p.Args...)
+    cmd.Dir = request.SourceRoot
+    cmd.Stdin = bytes.NewReader(input)
+    cmd.Stdout = &output
+    cmd.Stderr = os.Stdout
+    err = b.executor().Run(cmd)
+    if err != nil { return nil, fmt.Errorf("plugin %s: %s", name, err) }
+
+    response := plugin.Response{}
+    err = json.Unmarshal(output.Bytes(), &response)
+    if err != nil { return nil, fmt.Errorf("plugin %s: invalid response: %s", name, err) }
+
+    failed := 0
+    for _, diagnostic := range response.Diagnostics {
+        fmt.Printf("%s: %s\n", name, diagnostic)
+        if diagnostic.Severity != plugin.Warning {
+            failed++
+        }
+    }
+    if failed > 0 { return nil, fmt.Errorf("plugin %s reported %d errors", name, failed) }
+    return response.Files, nil
+}
+
+// finishOutputs gives files written by plugins and aggregates the same
+// timestamps, modes and compression as handler outputs.
+func (b *Builder) finishOutputs(name string, buildDir string, source string, files []plugin.File, modTime time.Time) error {
+    for _, file := range files {
+        dest := filepath.Join(buildDir, filepath.FromSlash(file.Path))
+        if !strings.HasPrefix(dest, buildDir + string(filepath.Separator)) {
+            return fmt.Errorf("%s wrote outside the build directory: %s", name, file.Path)
+        }
+        steps := []Step{setMode(dataMode), b.recordSource}
+        if file.Compress {
+            steps = []Step{b.compress, setMode(dataMode), b.recordSource}
+        }
+        err := runSteps(&Artifact{source, dest, modTime}, steps)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+// pluginCommand resolves a command containing a path separator against the
+// site root.
+func pluginCommand(srcDir string, command string) string {
+    if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) {
+        return filepath.Join(srcDir, command)
+    }
+    return command
+}
@@ -0,0 +1,84 @@
+package build
+
+import (
+   "fmt"
+   "os"
+   "time"
+   "io/ioutil"
+   "sort"
+   "path/filepath"
+   "crypto/sha256"
+)
+
+func normalizeTree(buildDir string, date time.Time) error {
+    return filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        mode := os.FileMode(0644)
+        if info.IsDir() || info.Mode() & 0111 != 0 {
+            mode = 0755
+        }
+        err = os.Chmod(path, mode)
+        if err != nil { return err }
+        return setFileTimestamp(path, date)
+    })
+}
+
+func manifest(buildDir string) (map[string]string, error) {
+    entries := map[string]string{}
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        entry := fmt.Sprintf("%s %d", info.Mode(), info.ModTime().Unix())
+        if !info.IsDir() {
+            bs, err := ioutil.ReadFile(path)
+            if err != nil { return err }
+            entry += fmt.Sprintf(" %x", sha256.Sum256(bs))
+        }
+        entries[filepath.ToSlash(relativePath)] = entry
+        return nil
+    })
+    return entries, err
+}
+
+func (b *Builder) VerifyReproducible(srcDir string) error {
+    reproducible := *b
+    reproducible.Options.Reproducible = true
+    manifests := []map[string]string{}
+    for i := 0; i < 2; i++ {
+        buildDir, err := mkdirTemp()
+        if err != nil { return err }
+        defer os.RemoveAll(buildDir)
+
+        _, err = reproducible.Build(srcDir, buildDir)
+        if err != nil { return err }
+
+        entries, err := manifest(buildDir)
+        if err != nil { return err }
+        manifests = append(manifests, entries)
+    }
+
+    paths := []string{}
+    for path, _ := range manifests[0] {
+        paths = append(paths, path)
+    }
+    for path, _ := range manifests[1] {
+        if _, found := manifests[0][path]; !found {
+            paths = append(paths, path)
+        }
+    }
+    sort.Strings(paths)
+
+    differences := 0
+    for _, path := range paths {
+        first, second := manifests[0][path], manifests[1][path]
+        if first == second { continue }
+        fmt.Printf("%s\n  - %s\n  + %s\n", path, first, second)
+        differences++
+    }
+    if differences > 0 {
+        return fmt.Errorf("build is not reproducible: %d of %d paths differ", differences, len(paths))
+    }
+    fmt.Printf("build is reproducible: %d paths identical\n", len(paths))
+    return nil
+}
@@ -0,0 +1,254 @@
+package build
+
+import (
+   "fmt"
+   "bytes"
+   "os"
+   "time"
+   "path"
+   "io/ioutil"
+   "strings"
+   "path/filepath"
+   "encoding/xml"
+   "compress/gzip"
+   "github.com/GlenKelley/dev/bundle"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/linkcheck"
+   "github.com/GlenKelley/dev/redirect"
+   "github.com/GlenKelley/dev/s3"
+   "github.com/GlenKelley/dev/sri"
+)
+
+const productionEnv = "production"
+
+type SitemapURL struct {
+    Loc string `xml:"loc"`
+    LastMod string `xml:"lastmod"`
+}
+
+type Sitemap struct {
+    XMLName xml.Name `xml:"urlset"`
+    Namespace string `xml:"xmlns,attr"`
+    URLs []SitemapURL `xml:"url"`
+}
+
+func writeSitemap(buildDir string, siteURL string, queue *compressionQueue) error {
+    sitemap := Sitemap{Namespace: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() || filepath.Ext(path) != ".html" { return nil }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        loc := siteURL + "/" + filepath.ToSlash(s3.ItemPath(relativePath))
+        lastMod := info.ModTime().UTC().Format(time.RFC3339)
+        sitemap.URLs = append(sitemap.URLs, SitemapURL{loc, lastMod})
+        return nil
+    })
+    if err != nil { return err }
+
+    dest := filepath.Join(buildDir, "sitemap.xml")
+    file, err := os.Create(dest)
+    if err != nil { return err }
+    defer file.Close()
+
+    _, err = file.WriteString(xml.Header)
+    if err != nil { return err }
+    encoder := xml.NewEncoder(file)
+    encoder.Indent("", "  ")
+    err = encoder.Encode(sitemap)
+    if err != nil { return err }
+    file.Close()
+
+    queue.add(dest)
+
+    err = os.Chmod(dest, dataMode)
+    if err != nil { return err }
+
+    return nil
+}
+
+func writeRobots(buildDir string, env string, siteURL string) error {
+    robots := "User-agent: *\nDisallow: /\n"
+    if env == productionEnv {
+        robots = "User-agent: *\nDisallow:\n\nSitemap: " + siteURL + "/sitemap.xml\n"
+    }
+    dest := filepath.Join(buildDir, "robots.txt")
+    err := ioutil.WriteFile(dest, []byte(robots), dataMode)
+    if err != nil { return err }
+
+    return os.Chmod(dest, dataMode)
+}
+
+func writeBundles(buildDir string, bundles config.Bundles, queue *compressionQueue) error {
+    if len(bundles.Entries) == 0 { return nil }
+    read := func(id string) ([]byte, error) {
+        return readBuildFile(filepath.Join(buildDir, filepath.FromSlash(id)))
+    }
+    outputPaths := []string{}
+    for _, entry := range bundles.Entries {
+        outputPaths = append(outputPaths, strings.TrimSuffix(entry, path.Ext(entry)) + ".bundle.js")
+    }
+    outputs, err := bundle.NewBundler(read).Bundle(bundles.Entries, outputPaths, bundles.Common)
+    if err != nil { return err }
+
+    for _, output := range outputs {
+        fmt.Printf("bundled %s (%d modules)\n", output.Path, len(output.Modules))
+        dest := filepath.Join(buildDir, filepath.FromSlash(output.Path))
+        err = MkdirAll(filepath.Dir(dest))
+        if err != nil { return err }
+
+        err = ioutil.WriteFile(dest, []byte(output.Source), dataMode)
+        if err != nil { return err }
+
+        queue.add(dest)
+
+        modTime := time.Time{}
+        for _, id := range output.Modules {
+            info, err := os.Stat(filepath.Join(buildDir, filepath.FromSlash(id)))
+            if err != nil { return err }
+            if info.ModTime().After(modTime) {
+                modTime = info.ModTime()
+            }
+        }
+        err = setFileTimestamp(dest, modTime)
+        if err != nil { return err }
+
+        err = os.Chmod(dest, dataMode)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+func readBuildFile(path string) ([]byte, error) {
+    bs, err := ioutil.ReadFile(path)
+    if err != nil { return nil, err }
+    if !isGzipped(bs) {
+        return bs, nil
+    }
+    reader, err := gzip.NewReader(bytes.NewReader(bs))
+    if err != nil { return nil, err }
+    defer reader.Close()
+    return ioutil.ReadAll(reader)
+}
+
+func isGzipped(bs []byte) bool {
+    return len(bs) >= 2 && bs[0] == 0x1f && bs[1] == 0x8b
+}
+
+func addIntegrity(buildDir string) error {
+    return filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() || filepath.Ext(path) != ".html" { return nil }
+
+        read := func(url string) ([]byte, error) {
+            ref := filepath.Join(filepath.Dir(path), filepath.FromSlash(url))
+            if strings.HasPrefix(url, "/") {
+                ref = filepath.Join(buildDir, filepath.FromSlash(url))
+            }
+            bs, err := readBuildFile(ref)
+            if err != nil { return nil, fmt.Errorf("%s: %s", path, err) }
+            return bs, nil
+        }
+
+        page, err := ioutil.ReadFile(path)
+        if err != nil { return err }
+        annotated, err := sri.Annotate(page, read)
+        if err != nil { return err }
+        if bytes.Equal(page, annotated) { return nil }
+
+        err = ioutil.WriteFile(path, annotated, info.Mode())
+        if err != nil { return err }
+        return setFileTimestamp(path, info.ModTime())
+    })
+}
+
+// copyRedirects validates the site's redirects file and copies it into the
+// build for deploy.
+func copyRedirects(srcDir string, buildDir string) ([]redirect.Redirect, error) {
+    src := filepath.Join(srcDir, redirect.File)
+    info, err := os.Stat(src)
+    if os.IsNotExist(err) { return nil, nil }
+    if err != nil { return nil, err }
+    content, err := ioutil.ReadFile(src)
+    if err != nil { return nil, err }
+    redirects, err := redirect.Parse(content)
+    if err != nil { return nil, err }
+
+    dest := filepath.Join(buildDir, redirect.File)
+    err = ioutil.WriteFile(dest, content, dataMode)
+    if err != nil { return nil, err }
+    err = os.Chmod(dest, dataMode)
+    if err != nil { return nil, err }
+    return redirects, setFileTimestamp(dest, info.ModTime())
+}
+
+// checkLinks reports internal links between built pages that lead nowhere.
+// Pages are checked after compilation, so reports name the source and, for
+// copies, the line; compiled pages give the output's line as context.
+func checkLinks(srcDir string, buildDir string, redirects []redirect.Redirect, sources *sourceMap) error {
+    keys := map[string]bool{}
+    pages := []string{}
+    err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return err }
+        if info.IsDir() { return nil }
+        relativePath, err := filepath.Rel(buildDir, path)
+        if err != nil { return err }
+        if relativePath == redirect.File { return nil }
+        keys[filepath.ToSlash(s3.ItemPath(relativePath))] = true
+        ext := filepath.Ext(path)
+        if ext == ".html" || ext == ".css" {
+            pages = append(pages, relativePath)
+        }
+        return nil
+    })
+    if err != nil { return err }
+
+    exists := func(key string) bool {
+        return keys[key] || keys[key + "/index"]
+    }
+    invalid := redirect.Check(redirects, exists)
+    for _, err := range invalid {
+        fmt.Println(err)
+    }
+    for _, r := range redirects {
+        keys[r.Key()] = true
+    }
+
+    broken := 0
+    for _, page := range pages {
+        content, err := readBuildFile(filepath.Join(buildDir, page))
+        if err != nil { return err }
+        file := filepath.ToSlash(page)
+        for _, ref := range linkcheck.Extract(file, content) {
+            if !linkcheck.IsInternal(ref.URL) { continue }
+            key := linkcheck.Resolve(s3.ItemPath(file), ref.URL)
+            if keys[key] || keys[key + "/index"] { continue }
+            fmt.Println(brokenLink(srcDir, buildDir, sources, ref))
+            broken++
+        }
+    }
+    if len(invalid) > 0 {
+        return fmt.Errorf("%d invalid redirects", len(invalid))
+    }
+    if broken > 0 {
+        return fmt.Errorf("%d broken links", broken)
+    }
+    return nil
+}
+
+// brokenLink describes a broken reference by its source where the output was
+// made from one.
+func brokenLink(srcDir string, buildDir string, sources *sourceMap, ref linkcheck.Reference) string {
+    source, found := sources.source(filepath.Join(buildDir, filepath.FromSlash(ref.File)))
+    if !found {
+        return fmt.Sprintf("output %s:%d: broken link %s", ref.File, ref.Line, ref.URL)
+    }
+    relativePath, err := filepath.Rel(srcDir, source.Path)
+    if err != nil {
+        relativePath = source.Path
+    }
+    if source.SameLines {
+        return fmt.Sprintf("%s:%d: broken link %s (output %s)", filepath.ToSlash(relativePath), ref.Line, ref.URL, ref.File)
+    }
+    return fmt.Sprintf("%s: broken link %s (output %s:%d)", filepath.ToSlash(relativePath), ref.URL, ref.File, ref.Line)
+}
@@ -0,0 +1,361 @@
+package bundle
+
+import (
+    "bytes"
+    "fmt"
+    "path"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+var (
+    requirePattern = regexp.MustCompile(`\brequire\(\s*['"]([^'"]+)['"]\s*\)`)
+    importFromPattern = regexp.MustCompile(`(?m)^[ \t]*import\s+([^'";]+?)\s+from\s+['"]([^'"]+)['"][ \t]*;?`)
+    importPattern = regexp.MustCompile(`(?m)^[ \t]*import\s+['"]([^'"]+)['"][ \t]*;?`)
+    exportFromPattern = regexp.MustCompile(`(?m)^[ \t]*export\s+(\*|\{[^}]*\})\s+from\s+['"]([^'"]+)['"][ \t]*;?`)
+    exportListPattern = regexp.MustCompile(`(?m)^[ \t]*export\s*\{([^}]*)\}[ \t]*;?`)
+    exportDefaultPattern = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+`)
+    exportDefaultDeclarationPattern = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+((?:(?:async\s+)?function\b\s*\*?\s*|class\s+)([A-Za-z_$][\w$]*))`)
+    exportDeclarationPattern = regexp.MustCompile(`(?m)^([ \t]*)export\s+((?:(?:var|let|const|class)\s+|(?:async\s+)?function\b\s*\*?\s*)([A-Za-z_$][\w$]*))`)
+)
+
+const runtime = `(function(g){if(g.__require)return;var d={},c={};` +
+    `g.__define=function(id,f){d[id]=f};` +
+    `g.__require=function(id){if(c[id])return c[id].exports;var m=c[id]={exports:{}};d[id].call(m.exports,m,m.exports,g.__require);return m.exports}` +
+    `})(typeof self !== "undefined" ? self : this);`
+
+type Module struct {
+    ID string
+    Source string
+    Deps []string
+}
+
+type Output struct {
+    Path string
+    Source string
+    Modules []string
+}
+
+type Bundler struct {
+    Read func(id string) ([]byte, error)
+    modules map[string]*Module
+}
+
+func NewBundler(read func(id string) ([]byte, error)) *Bundler {
+    return &Bundler{read, map[string]*Module{}}
+}
+
+// Bundle writes one output per entry, moving modules reachable from more than
+// one entry into the common chunk, which must be loaded before the entries.
+func (b *Bundler) Bundle(entries []string, outputPaths []string, commonPath string) ([]Output, error) {
+    orders := make([][]string, len(entries))
+    users := map[string]int{}
+    for i, entry := range entries {
+        entry = path.Clean(entry)
+        order := []string{}
+        err := b.visit(entry, map[string]bool{}, &order)
+        if err != nil { return nil, err }
+        orders[i] = order
+        for _, id := range order {
+            users[id]++
+        }
+    }
+
+    shared := func(id string) bool {
+        return commonPath != "" && len(entries) > 1 && users[id] > 1
+    }
+    outputs := []Output{}
+    common := Output{Path: commonPath}
+    for _, order := range orders {
+        for _, id := range order {
+            if shared(id) && !contains(common.Modules, id) {
+                common.Modules = append(common.Modules, id)
+            }
+        }
+    }
+    if len(common.Modules) > 0 {
+        common.Source = b.render(common.Modules, "")
+        outputs = append(outputs, common)
+    }
+    for i, order := range orders {
+        output := Output{Path: outputPaths[i]}
+        for _, id := range order {
+            if !shared(id) {
+                output.Modules = append(output.Modules, id)
+            }
+        }
+        output.Source = b.render(output.Modules, path.Clean(entries[i]))
+        outputs = append(outputs, output)
+    }
+    return outputs, nil
+}
+
+func (b *Bundler) visit(id string, visiting map[string]bool, order *[]string) error {
+    if visiting[id] || contains(*order, id) { return nil }
+    visiting[id] = true
+    module, err := b.load(id)
+    if err != nil { return err }
+    for _, dep := range module.Deps {
+        err = b.visit(dep, visiting, order)
+        if err != nil { return fmt.Errorf("%s: %s", id, err) }
+    }
+    *order = append(*order, id)
+    return nil
+}
+
+func (b *Bundler) render(ids []string, entry string) string {
+    var buffer bytes.Buffer
+    buffer.WriteString(runtime)
+    buffer.WriteString("\n")
+    for _, id := range ids {
+        module := b.modules[id]
+        buffer.WriteString("__define(" + strconv.Quote(id) + ",function(module,exports,require){\n")
+        buffer.WriteString(module.Source)
+        buffer.WriteString("\n});\n")
+    }
+    if entry != "" {
+        buffer.WriteString("__require(" + strconv.Quote(entry) + ");\n")
+    }
+    return buffer.String()
+}
+
+func (b *Bundler) load(id string) (*Module, error) {
+    module := b.modules[id]
+    if module != nil { return module, nil }
+
+    bytes, err := b.Read(id)
+    if err != nil { return nil, err }
+
+    module = &Module{ID: id}
+    source := transform(string(bytes))
+
+    var resolveErr error
+    module.Source = replaceCode(requirePattern, source, func(match []string) string {
+        dep, err := b.resolve(id, match[1])
+        if err != nil {
+            if resolveErr == nil {
+                resolveErr = err
+            }
+            return match[0]
+        }
+        if !contains(module.Deps, dep) {
+            module.Deps = append(module.Deps, dep)
+        }
+        return "require(" + strconv.Quote(dep) + ")"
+    })
+    if resolveErr != nil { return nil, fmt.Errorf("%s: %s", id, resolveErr) }
+    b.modules[id] = module
+    return module, nil
+}
+
+func (b *Bundler) resolve(from string, specifier string) (string, error) {
+    var base string
+    switch {
+    case strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../"):
+        base = path.Join(path.Dir(from), specifier)
+    case strings.HasPrefix(specifier, "/"):
+        base = path.Clean(specifier[1:])
+    default:
+        return "", fmt.Errorf("cannot resolve package import %q", specifier)
+    }
+    for _, candidate := range []string{base, base + ".js", path.Join(base, "index.js")} {
+        if b.modules[candidate] != nil { return candidate, nil }
+        if path.Ext(candidate) != ".js" { continue }
+        _, err := b.Read(candidate)
+        if err == nil { return candidate, nil }
+    }
+    return "", fmt.Errorf("cannot resolve %q", specifier)
+}
+
+func transform(source string) string {
+    imports := 0
+    esModule := false
+    exported := []string{}
+
+    source = replaceCode(exportFromPattern, source, func(match []string) string {
+        esModule = true
+        module := "require(" + strconv.Quote(match[2]) + ")"
+        if match[1] == "*" {
+            return "(function(m){for(var k in m)if(k!=='default')exports[k]=m[k]})(" + module + ");"
+        }
+        imports++
+        name := fmt.Sprintf("__import%d", imports)
+        statements := []string{"var " + name + " = " + module + ";"}
+        for _, binding := range bindings(match[1]) {
+            statements = append(statements, "exports." + binding[1] + " = " + name + "." + binding[0] + ";")
+        }
+        return strings.Join(statements, " ")
+    })
+
+    source = replaceCode(importFromPattern, source, func(match []string) string {
+        imports++
+        name := fmt.Sprintf("__import%d", imports)
+        statements := []string{"var " + name + " = require(" + strconv.Quote(match[2]) + ");"}
+        clause := strings.TrimSpace(match[1])
+        if !strings.HasPrefix(clause, "{") && !strings.HasPrefix(clause, "*") {
+            parts := strings.SplitN(clause, ",", 2)
+            defaultName := strings.TrimSpace(parts[0])
+            statements = append(statements, "var " + defaultName + " = " + name + " && " + name + ".__esModule ? " + name + ".default : " + name + ";")
+            clause = ""
+            if len(parts) == 2 {
+                clause = strings.TrimSpace(parts[1])
+            }
+        }
+        if strings.HasPrefix(clause, "*") {
+            namespace := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(clause[1:]), "as"))
+            statements = append(statements, "var " + namespace + " = " + name + ";")
+        } else if clause != "" {
+            for _, binding := range bindings(clause) {
+                statements = append(statements, "var " + binding[1] + " = " + name + "." + binding[0] + ";")
+            }
+        }
+        return strings.Join(statements, " ")
+    })
+
+    source = replaceCode(importPattern, source, func(match []string) string {
+        return "require(" + strconv.Quote(match[1]) + ");"
+    })
+
+    source = replaceCode(exportListPattern, source, func(match []string) string {
+        esModule = true
+        for _, binding := range bindings("{" + match[1] + "}") {
+            exported = append(exported, "exports." + binding[1] + " = " + binding[0] + ";")
+        }
+        return ""
+    })
+
+    source = replaceCode(exportDefaultDeclarationPattern, source, func(match []string) string {
+        esModule = true
+        exported = append(exported, "exports.default = " + match[3] + ";")
+        return match[1] + match[2]
+    })
+
+    source = replaceCode(exportDefaultPattern, source, func(match []string) string {
+        esModule = true
+        return match[1] + "exports.default = "
+    })
+
+    source = replaceCode(exportDeclarationPattern, source, func(match []string) string {
+        esModule = true
+        exported = append(exported, "exports." + match[3] + " = " + match[3] + ";")
+        return match[1] + match[2]
+    })
+
+    if esModule {
+        source = "exports.__esModule = true;\n" + source + "\n" + strings.Join(exported, "\n")
+    }
+    return source
+}
+
+// replaceCode replaces the matches of pattern that start in code, leaving
+// those inside comments and string literals alone.
+func replaceCode(pattern *regexp.Regexp, source string, replace func(match []string) string) string {
+    code := codeMask(source)
+    var buffer bytes.Buffer
+    last := 0
+    for _, indexes := range pattern.FindAllStringSubmatchIndex(source, -1) {
+        start := indexes[0]
+        for start < indexes[1] && strings.IndexByte(" \t\r\n", source[start]) >= 0 {
+            start++
+        }
+        if start < indexes[1] && !code[start] { continue }
+        match := make([]string, len(indexes) / 2)
+        for i := range match {
+            if indexes[2*i] >= 0 {
+                match[i] = source[indexes[2*i]:indexes[2*i+1]]
+            }
+        }
+        buffer.WriteString(source[last:indexes[0]])
+        buffer.WriteString(replace(match))
+        last = indexes[1]
+    }
+    buffer.WriteString(source[last:])
+    return buffer.String()
+}
+
+// codeMask reports for each byte of source whether it is code rather than
+// part of a comment, string, template or regular expression literal.
+func codeMask(source string) []bool {
+    code := make([]bool, len(source))
+    previous := byte(0)
+    for i := 0; i < len(source); i++ {
+        c := source[i]
+        end := i
+        switch {
+        case c == '/' && i + 1 < len(source) && source[i+1] == '/':
+            end = strings.IndexByte(source[i:], '\n')
+            if end < 0 {
+                end = len(source)
+            } else {
+                end += i - 1
+            }
+        case c == '/' && i + 1 < len(source) && source[i+1] == '*':
+            end = strings.Index(source[i+2:], "*/")
+            if end < 0 {
+                end = len(source)
+            } else {
+                end += i + 3
+            }
+        case c == '"' || c == '\'' || c == '`':
+            end = literalEnd(source, i, c)
+        case c == '/' && (previous == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", previous) >= 0):
+            end = literalEnd(source, i, '/')
+        default:
+            code[i] = true
+            if strings.IndexByte(" \t\r\n", c) < 0 {
+                previous = c
+            }
+            continue
+        }
+        // A literal or comment ends an expression, like an identifier would.
+        previous = 'a'
+        i = end
+    }
+    return code
+}
+
+// literalEnd returns the index of the unescaped delimiter closing the literal
+// starting at start. Regular expressions may contain the delimiter inside a
+// character class.
+func literalEnd(source string, start int, delimiter byte) int {
+    class := false
+    for i := start + 1; i < len(source); i++ {
+        switch c := source[i]; {
+        case c == '\\':
+            i++
+        case c == '\n' && delimiter != '`':
+            return i
+        case delimiter == '/' && c == '[':
+            class = true
+        case delimiter == '/' && c == ']':
+            class = false
+        case c == delimiter && !class:
+            return i
+        }
+    }
+    return len(source)
+}
+
+func bindings(clause string) [][2]string {
+    clause = strings.TrimSpace(clause)
+    clause = strings.TrimSuffix(strings.TrimPrefix(clause, "{"), "}")
+    result := [][2]string{}
+    for _, binding := range strings.Split(clause, ",") {
+        fields := strings.Fields(binding)
+        switch {
+        case len(fields) == 1:
+            result = append(result, [2]string{fields[0], fields[0]})
+        case len(fields) == 3 && fields[1] == "as":
+            result = append(result, [2]string{fields[0], fields[2]})
+        }
+    }
+    return result
+}
+
+func contains(ids []string, id string) bool {
+    for _, other := range ids {
+        if other == id { return true }
+    }
+    return false
+}
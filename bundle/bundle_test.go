@@ -0,0 +1,109 @@
+package bundle
+
+import (
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func reader(files map[string]string) func(id string) ([]byte, error) {
+    return func(id string) ([]byte, error) {
+        source, found := files[id]
+        if !found { return nil, fmt.Errorf("%s not found", id) }
+        return []byte(source), nil
+    }
+}
+
+func TestDeps(t *testing.T) {
+    tests := []struct {
+        name string
+        source string
+        deps []string
+    }{
+        {"require", "var a = require('./a');", []string{"a.js"}},
+        {"import", "import a from './a';\nimport {b as c} from \"./b.js\";", []string{"a.js", "b.js"}},
+        {"export from", "export * from './a';", []string{"a.js"}},
+        {"line comment", "// require('./missing')\nvar a = require('./a');", []string{"a.js"}},
+        {"block comment", "/* import x from './missing';\nrequire('./missing') */", nil},
+        {"string", "var s = \"require('./missing')\";", nil},
+        {"escaped string", "var s = 'it\\'s require(\"./missing\")';", nil},
+        {"template", "var s = `\nimport x from './missing';\n`;", nil},
+        {"regular expression", "var r = /'/; var a = require('./a');", []string{"a.js"}},
+        {"division", "var x = 1 / 2; var a = require('./a'); // /", []string{"a.js"}},
+    }
+    for _, test := range tests {
+        bundler := NewBundler(reader(map[string]string{"main.js": test.source, "a.js": "", "b.js": ""}))
+        module, err := bundler.load("main.js")
+        if err != nil {
+            t.Errorf("%s: %s", test.name, err)
+            continue
+        }
+        if !reflect.DeepEqual(module.Deps, test.deps) {
+            t.Errorf("%s: deps %v, want %v", test.name, module.Deps, test.deps)
+        }
+    }
+}
+
+func TestTransform(t *testing.T) {
+    tests := []struct {
+        name string
+        source string
+        contains []string
+    }{
+        {"function", "export function f() {}", []string{"\nfunction f() {}", "exports.f = f;"}},
+        {"async function", "export async function f() {}", []string{"\nasync function f() {}", "exports.f = f;"}},
+        {"generator", "export function* g() {}", []string{"\nfunction* g() {}", "exports.g = g;"}},
+        {"const", "export const x = 1;", []string{"\nconst x = 1;", "exports.x = x;"}},
+        {"default expression", "export default 42;", []string{"exports.default = 42;"}},
+        {"default function", "export default function f() {}", []string{"\nfunction f() {}", "exports.default = f;"}},
+        {"default async function", "export default async function f() {}", []string{"\nasync function f() {}", "exports.default = f;"}},
+        {"default anonymous async", "export default async function() {}", []string{"exports.default = async function() {}"}},
+        {"list", "var a = 1;\nexport { a as b };", []string{"exports.b = a;"}},
+        {"commented export", "// export function f() {}", []string{"// export function f() {}"}},
+    }
+    for _, test := range tests {
+        source := transform(test.source)
+        for _, want := range test.contains {
+            if !strings.Contains(source, want) {
+                t.Errorf("%s: transform(%q) = %q, want it to contain %q", test.name, test.source, source, want)
+            }
+        }
+        if strings.Contains(source, "export ") && !strings.Contains(test.source, "// export") {
+            t.Errorf("%s: transform(%q) = %q, still exports", test.name, test.source, source)
+        }
+    }
+}
+
+func TestBundle(t *testing.T) {
+    bundler := NewBundler(reader(map[string]string{
+        "a.js": "import shared from './shared';",
+        "b.js": "require('./shared');",
+        "shared.js": "export default 1;",
+    }))
+    outputs, err := bundler.Bundle([]string{"a.js", "b.js"}, []string{"a.out.js", "b.out.js"}, "common.js")
+    if err != nil { t.Fatal(err) }
+    modules := map[string][]string{}
+    for _, output := range outputs {
+        modules[output.Path] = output.Modules
+    }
+    want := map[string][]string{
+        "common.js": []string{"shared.js"},
+        "a.out.js": []string{"a.js"},
+        "b.out.js": []string{"b.js"},
+    }
+    if !reflect.DeepEqual(modules, want) {
+        t.Errorf("modules %v, want %v", modules, want)
+    }
+    if !strings.HasSuffix(outputs[1].Source, "__require(\"a.js\");\n") {
+        t.Errorf("entry output does not require its entry: %q", outputs[1].Source)
+    }
+}
+
+func TestMissingDependency(t *testing.T) {
+    bundler := NewBundler(reader(map[string]string{"main.js": "require('./missing');"}))
+    _, err := bundler.Bundle([]string{"main.js"}, []string{"out.js"}, "")
+    if err == nil || !strings.Contains(err.Error(), `cannot resolve "./missing"`) {
+        t.Errorf("error %v, want cannot resolve", err)
+    }
+}
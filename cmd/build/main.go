@@ -0,0 +1,128 @@
+package main
+
+import (
+   "fmt"
+   "os"
+   "time"
+   "flag"
+   "strings"
+   "strconv"
+   "path/filepath"
+   "github.com/GlenKelley/dev/build"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/git"
+)
+
+type Flags struct {
+    Options build.Options
+    Sites []string
+    SrcDir string
+    OutDir string
+    Addr string
+}
+
+func main() {
+   f := flags()
+   command := flag.Arg(0)
+
+   groot, err := config.SourceRoot(f.SrcDir)
+   if command == "doctor" {
+       if !build.Doctor(groot, doctorSites(groot, err, f)) { os.Exit(1) }
+       return
+   }
+   panicOnError(err)
+   fmt.Printf("building for environment [%s]\n", f.Options.Env)
+
+   f.Options.Commit, f.Options.SourceDate = sourceVersion(groot)
+   cfg, err := config.Load(groot)
+   panicOnError(err)
+   sites, err := cfg.Select(f.Sites)
+   panicOnError(err)
+   sites, err = config.WithOutput(sites, f.OutDir)
+   panicOnError(err)
+
+   if command == "serve" {
+       serve(groot, sites[0], f)
+       return
+   }
+   for _, site := range sites {
+       if site.Name != "" {
+           fmt.Printf("building site [%s]\n", site.Name)
+       }
+       options := f.Options
+       options.Site = site
+       builder := build.NewBuilder(options)
+       srcDir := filepath.Join(groot, site.Root)
+
+       if command == "verify-reproducible" {
+           err = builder.VerifyReproducible(srcDir)
+           panicOnError(err)
+           continue
+       }
+       result, err := builder.BuildInto(srcDir, site.Output)
+       panicOnError(err)
+       for _, aggregate := range result.Aggregates {
+           fmt.Printf("aggregated %d files into %d with %s\n", aggregate.Inputs, len(aggregate.Outputs), aggregate.Name)
+       }
+       fmt.Printf("built %d sources into %d files at %s in %s\n", len(result.Sources), len(result.Outputs), site.Output, result.Duration)
+   }
+}
+
+func flags() Flags {
+   envPtr := flag.String("env", "local", "environment")
+   urlPtr := flag.String("url", "http://akusete.com", "public site url")
+   goTargetsPtr := flag.String("go-targets", "", "comma separated GOOS/GOARCH pairs to cross compile go packages for")
+   srcPtr := flag.String("src", "", "source directory, defaults to the enclosing git repository")
+   outPtr := flag.String("out", "", "output directory, overriding configured site outputs")
+   sitesPtr := flag.String("site", "", "comma separated sites to build, defaults to all configured sites")
+   reproduciblePtr := flag.Bool("reproducible", false, "normalize output timestamps and modes to the commit")
+   keepDaePtr := flag.Bool("keep-dae", false, "deploy original collada files alongside converted models")
+   gzipPtr := flag.Bool("gzip", true, "gzip compressible outputs")
+   addrPtr := flag.String("addr", "localhost:8000", "address to serve the first selected site on with the serve command")
+   minifyPtr := flag.String("minify", "", "directory containing the closure compiler.jar, enables javascript minification")
+   flag.Parse()
+   f := Flags{}
+   f.Options.Env = *envPtr
+   f.Options.SiteURL = strings.TrimSuffix(*urlPtr, "/")
+   f.Options.KeepModelSources = *keepDaePtr
+   f.Options.NoCompress = !*gzipPtr
+   f.Options.ClosureDir = config.ExpandHome(*minifyPtr)
+   f.Options.Reproducible = *reproduciblePtr
+   f.SrcDir = *srcPtr
+   f.OutDir = *outPtr
+   f.Addr = *addrPtr
+   if *sitesPtr != "" {
+       f.Sites = strings.Split(*sitesPtr, ",")
+   }
+   if *goTargetsPtr != "" {
+       f.Options.GoTargets = strings.Split(*goTargetsPtr, ",")
+   }
+   return f
+}
+
+func doctorSites(groot string, err error, f Flags) []config.Site {
+    sites := []config.Site{config.Site{}}
+    if err != nil { return sites }
+    cfg, err := config.Load(groot)
+    if err != nil { return sites }
+    selected, err := cfg.Select(f.Sites)
+    if err != nil { return sites }
+    return selected
+}
+
+func sourceVersion(srcDir string) (string, time.Time) {
+    commit, err := git.HeadCommit(srcDir)
+    if err != nil {
+        commit = ""
+    }
+    date, err := git.HeadCommitTime(srcDir)
+    if err != nil {
+        seconds, _ := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+        date = time.Unix(seconds, 0)
+    }
+    return commit, date
+}
+
+func panicOnError (err error) {
+    if err != nil { panic(err) }
+}
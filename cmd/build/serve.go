@@ -0,0 +1,101 @@
+package main
+
+import (
+   "fmt"
+   "os"
+   "time"
+   "strings"
+   "net/http"
+   "path/filepath"
+   "github.com/GlenKelley/dev/build"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/livereload"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+// serve builds site and serves its output, rebuilding whenever a source
+// changes and telling open pages to reload, or to swap their stylesheets when
+// only styles changed.
+func serve(groot string, site config.Site, f Flags) {
+    srcDir := filepath.Join(groot, site.Root)
+    options := f.Options
+    options.Site = site
+    server := livereload.NewServer(site.Output)
+
+    snapshot := sourceSnapshot(srcDir, site.Output)
+    server.Send(rebuild(srcDir, site, options))
+    go func() {
+        panicOnError(http.ListenAndServe(f.Addr, server))
+    }()
+    fmt.Printf("serving %s at http://%s/\n", site.Output, f.Addr)
+
+    for {
+        time.Sleep(pollInterval)
+        next := sourceSnapshot(srcDir, site.Output)
+        changed := changedSources(snapshot, next)
+        if len(changed) == 0 { continue }
+        snapshot = next
+
+        event := rebuild(srcDir, site, options)
+        if event.Type == livereload.Reload && onlyStyles(changed) {
+            event.Type = livereload.CSS
+        }
+        server.Send(event)
+    }
+}
+
+func rebuild(srcDir string, site config.Site, options build.Options) livereload.Event {
+    result, err := build.NewBuilder(options).BuildInto(srcDir, site.Output)
+    if err != nil {
+        fmt.Println(err)
+        messages := []string{}
+        for _, source := range result.Sources {
+            if source.Err != nil {
+                messages = append(messages, source.Path + ": " + source.Err.Error())
+            }
+        }
+        if len(messages) == 0 {
+            messages = append(messages, err.Error())
+        }
+        return livereload.Event{Type: livereload.Error, Data: strings.Join(messages, "\n\n")}
+    }
+    fmt.Printf("built %d sources into %d files in %s\n", len(result.Sources), len(result.Outputs), result.Duration)
+    return livereload.Event{Type: livereload.Reload}
+}
+
+func sourceSnapshot(srcDir string, outputDir string) map[string]string {
+    snapshot := map[string]string{}
+    filepath.Walk(srcDir, func (path string, info os.FileInfo, err error) error {
+        if err != nil { return nil }
+        if info.IsDir() && (info.Name() == ".git" || path == outputDir) { return filepath.SkipDir }
+        if !info.IsDir() {
+            snapshot[path] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+        }
+        return nil
+    })
+    return snapshot
+}
+
+func changedSources(previous map[string]string, next map[string]string) []string {
+    changed := []string{}
+    for path, version := range next {
+        if previous[path] != version {
+            changed = append(changed, path)
+        }
+    }
+    for path := range previous {
+        if _, found := next[path]; !found {
+            changed = append(changed, path)
+        }
+    }
+    return changed
+}
+
+func onlyStyles(paths []string) bool {
+    for _, path := range paths {
+        ext := filepath.Ext(path)
+        if ext != ".less" && ext != ".css" { return false }
+    }
+    return true
+}
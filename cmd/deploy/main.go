@@ -3,24 +3,91 @@ package main
 import (
    "os"
    "fmt"
+   "io/ioutil"
    "flag"
    "strings"
-   "os/exec"
-   "path"
    "path/filepath"
+   "github.com/GlenKelley/dev/command"
+   "github.com/GlenKelley/dev/config"
+   "github.com/GlenKelley/dev/redirect"
    "github.com/GlenKelley/dev/s3"
 )
 
+var executor command.Executor = command.System{}
+
 func main() {
     concurrent := flag.Bool("c", true, "run file uploads concurrently")
-    bucket := flag.String("bucket", "akusete.com", "s3 destination bucket")
+    bucket := flag.String("bucket", "akusete.com", "s3 destination bucket for the unnamed site when it has no configured bucket")
+    siteNames := flag.String("site", "", "comma separated sites to deploy, defaults to all configured sites")
+    src := flag.String("src", "", "source directory containing the build config, defaults to the enclosing git repository")
+    out := flag.String("out", "", "build output directory to deploy, overriding configured site outputs")
     flag.Parse()
-    
-    buildDir := path.Join(os.Getenv("HOME"), "Sites")
-    c, err := walkDir(buildDir, *bucket, *concurrent)
+
+    groot, err := config.SourceRoot(*src)
+    panicOnError(err)
+    cfg, err := config.Load(groot)
+    panicOnError(err)
+    names := []string{}
+    if *siteNames != "" {
+        names = strings.Split(*siteNames, ",")
+    }
+    sites, err := cfg.Select(names)
     panicOnError(err)
-    err = <- c
+    sites, err = config.WithOutput(sites, *out)
     panicOnError(err)
+    buckets, err := siteBuckets(sites, *bucket)
+    panicOnError(err)
+
+    for i, site := range sites {
+        siteBucket := buckets[i]
+        fmt.Printf("deploying %s to %s\n", site.Output, siteBucket)
+        c, err := walkDir(site.Output, siteBucket, *concurrent)
+        panicOnError(err)
+        err = <- c
+        panicOnError(err)
+        err = deployRedirects(site.Output, siteBucket)
+        panicOnError(err)
+    }
+}
+
+// siteBuckets returns the bucket each site deploys to. Only the unnamed site
+// may fall back to the -bucket flag, and no two sites may share a bucket,
+// since each deploy would overwrite the other.
+func siteBuckets(sites []config.Site, fallback string) ([]string, error) {
+    buckets := []string{}
+    deployed := map[string]string{}
+    for _, site := range sites {
+        bucket := site.Bucket
+        if bucket == "" {
+            if site.Name != "" { return nil, fmt.Errorf("site %s has no bucket", site.Name) }
+            bucket = fallback
+        }
+        if other, found := deployed[bucket]; found {
+            return nil, fmt.Errorf("sites %q and %q both deploy to bucket %s", other, site.Name, bucket)
+        }
+        deployed[bucket] = site.Name
+        buckets = append(buckets, bucket)
+    }
+    return buckets, nil
+}
+
+// deployRedirects creates an empty redirect object for each entry in the
+// redirects file the build validated and copied into buildDir.
+func deployRedirects(buildDir string, bucket string) error {
+    content, err := ioutil.ReadFile(filepath.Join(buildDir, redirect.File))
+    if os.IsNotExist(err) { return nil }
+    if err != nil { return err }
+    redirects, err := redirect.Parse(content)
+    if err != nil { return err }
+    for _, r := range redirects {
+        s3info, err := s3.GetS3Info(bucket, r.Key())
+        if err != nil { return err }
+        if s3info.RedirectURL == r.To { continue }
+        fmt.Printf("%s -> %s\n", r.From, r.To)
+        err = s3.UploadRedirect(bucket, r.Key(), r.To)
+        if err != nil { return err }
+    }
+    return nil
 }
 
 func walkDir(buildDir string, bucket string, concurrent bool) (chan error, error) {
@@ -43,7 +110,7 @@ func walkDir(buildDir string, bucket string, concurrent bool) (chan error, error
     visited := 0
     err := filepath.Walk(buildDir, func (path string, info os.FileInfo, err error) error {
         if err != nil { return err }
-        if info.IsDir() { 
+        if info.IsDir() || path == filepath.Join(buildDir, redirect.File) {
             return nil 
         }
         if (concurrent) {
@@ -104,6 +171,9 @@ func GetUploadInfo(path string, relativePath string, info os.FileInfo) (s3.S3Upl
         ".js": "gzip",
         ".json": "gzip",
         ".svg": "gzip",
+        ".xml": "gzip",
+        ".wasm": "gzip",
+        ".txt": "",
         ".jpg": "",
         ".png": "",
         ".gif": "",
@@ -116,28 +186,41 @@ func GetUploadInfo(path string, relativePath string, info os.FileInfo) (s3.S3Upl
         ".png": "image/png",
         ".svg": "image/svg+xml",
         ".json": "application/json",
+        ".xml": "application/xml; charset=UTF-8",
+        ".txt": "text/plain; charset=UTF-8",
+        ".wasm": "application/wasm",
         ".go": "binary/octet-stream",
     }
     
     ext := filepath.Ext(path)
     uploadInfo := s3.S3UploadInfo{}    
     uploadInfo.Encoding = encodings[ext]
+    if uploadInfo.Encoding == "gzip" && !IsGzipped(path) {
+        uploadInfo.Encoding = ""
+    }
     uploadInfo.ContentType = contentTypes[ext]
     uploadInfo.Public = true
     uploadInfo.ContentLength = info.Size()
     uploadInfo.ModTime = info.ModTime()
     md5, err := GetFileMD5(path)
     uploadInfo.MD5 = md5
-
-    if (ext == ".html") {
-        relativePath = relativePath[0:len(relativePath)-len(ext)]
-    }
-    uploadInfo.ItemPath = relativePath
+    uploadInfo.ItemPath = s3.ItemPath(relativePath)
     
     return uploadInfo, err
 }
 
 
+// IsGzipped reports whether the file starts with the gzip magic number, since
+// builds may be made with compression disabled.
+func IsGzipped(path string) bool {
+    file, err := os.Open(path)
+    if err != nil { return false }
+    defer file.Close()
+    magic := make([]byte, 2)
+    n, _ := file.Read(magic)
+    return n == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
 func GetFileMD5(path string) (string, error) {
     // file, err := os.Open(path)
     // if err != nil { return "", err }
@@ -146,7 +229,7 @@ func GetFileMD5(path string) (string, error) {
     // io.Copy(hash, file)
     // signature := make([]byte, 0, hash.Size())
     // md51 := hex.EncodeToString(hash.Sum(signature))
-    bytes, err := exec.Command("md5", "-q", path).Output()
+    bytes, err := command.Output(executor, command.New("md5", "-q", path))
     if err != nil { return "", nil } 
     md5 := strings.TrimSpace(string(bytes))
     return md5, nil
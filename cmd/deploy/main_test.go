@@ -0,0 +1,102 @@
+package main
+
+import (
+    "compress/gzip"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "github.com/GlenKelley/dev/command"
+    "github.com/GlenKelley/dev/config"
+)
+
+func TestGetUploadInfo(t *testing.T) {
+    defer func(e command.Executor) { executor = e }(executor)
+    recorder := &command.Recorder{Respond: func(c *command.Cmd) error {
+        _, err := io.WriteString(c.Stdout, "d41d8cd98f00b204e9800998ecf8427e\n")
+        return err
+    }}
+    executor = recorder
+
+    dir, err := ioutil.TempDir("", "deploy-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(dir)
+
+    tests := []struct {
+        file string
+        gzipped bool
+        encoding string
+        contentType string
+        itemPath string
+    }{
+        {"index.html", true, "gzip", "text/html; charset=UTF-8", "index"},
+        {"blog/post.html", false, "", "text/html; charset=UTF-8", "blog/post"},
+        {"js/app.js", true, "gzip", "application/x-javascript; charset=UTF-8", "js/app.js"},
+        {"img/a.png", false, "", "image/png", "img/a.png"},
+        {"robots.txt", false, "", "text/plain; charset=UTF-8", "robots.txt"},
+        {"data.bin", false, "", "", "data.bin"},
+    }
+    for _, test := range tests {
+        path := filepath.Join(dir, filepath.FromSlash(test.file))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        file, err := os.Create(path)
+        if err != nil { t.Fatal(err) }
+        var writer io.Writer = file
+        if test.gzipped {
+            writer = gzip.NewWriter(file)
+        }
+        io.WriteString(writer, "content")
+        if test.gzipped {
+            writer.(*gzip.Writer).Close()
+        }
+        file.Close()
+        info, err := os.Stat(path)
+        if err != nil { t.Fatal(err) }
+
+        recorder.Calls = nil
+        upload, err := GetUploadInfo(path, filepath.FromSlash(test.file), info)
+        if err != nil {
+            t.Errorf("%s: %s", test.file, err)
+            continue
+        }
+        if upload.Encoding != test.encoding || upload.ContentType != test.contentType || upload.ItemPath != test.itemPath {
+            t.Errorf("%s: encoding %q type %q item %q, want %q %q %q", test.file, upload.Encoding, upload.ContentType, upload.ItemPath, test.encoding, test.contentType, test.itemPath)
+        }
+        if !upload.Public || upload.ContentLength != info.Size() || !upload.ModTime.Equal(info.ModTime()) {
+            t.Errorf("%s: public %v length %d time %s, want the file's", test.file, upload.Public, upload.ContentLength, upload.ModTime)
+        }
+        if upload.MD5 != "d41d8cd98f00b204e9800998ecf8427e" {
+            t.Errorf("%s: md5 %q", test.file, upload.MD5)
+        }
+        if commands := recorder.Commands(); !reflect.DeepEqual(commands, []string{"md5 -q " + path}) {
+            t.Errorf("%s: ran %v", test.file, commands)
+        }
+    }
+}
+
+func TestSiteBuckets(t *testing.T) {
+    tests := []struct {
+        sites []config.Site
+        buckets []string
+        err string
+    }{
+        {[]config.Site{{}}, []string{"default.com"}, ""},
+        {[]config.Site{{Bucket: "example.com"}}, []string{"example.com"}, ""},
+        {[]config.Site{{Name: "blog", Bucket: "blog.com"}, {Name: "docs", Bucket: "docs.com"}}, []string{"blog.com", "docs.com"}, ""},
+        {[]config.Site{{Name: "blog"}}, nil, "site blog has no bucket"},
+        {[]config.Site{{Name: "blog", Bucket: "a.com"}, {Name: "docs", Bucket: "a.com"}}, nil, `sites "blog" and "docs" both deploy to bucket a.com`},
+    }
+    for _, test := range tests {
+        buckets, err := siteBuckets(test.sites, "default.com")
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err || !reflect.DeepEqual(buckets, test.buckets) {
+            t.Errorf("siteBuckets(%v) = %v, %q, want %v, %q", test.sites, buckets, message, test.buckets, test.err)
+        }
+    }
+}
@@ -0,0 +1,248 @@
+package collada
+
+import (
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+const Format = "mesh/1"
+
+// Model is the compact form written by the build. Each mesh is an indexed
+// triangle list: positions and normals hold 3 floats per vertex, uvs 2 floats
+// per vertex, and indices address vertices, 3 per triangle. mesh.schema.json
+// describes the format and loader.js loads it into typed arrays for WebGL.
+type Model struct {
+    Format string `json:"format"`
+    Meshes []Mesh `json:"meshes"`
+}
+
+type Mesh struct {
+    Name string `json:"name"`
+    Positions []float32 `json:"positions"`
+    Normals []float32 `json:"normals,omitempty"`
+    UVs []float32 `json:"uvs,omitempty"`
+    Indices []int `json:"indices"`
+}
+
+type document struct {
+    Geometries []geometry `xml:"library_geometries>geometry"`
+}
+
+type geometry struct {
+    ID string `xml:"id,attr"`
+    Name string `xml:"name,attr"`
+    Sources []source `xml:"mesh>source"`
+    Vertices vertices `xml:"mesh>vertices"`
+    Triangles []primitive `xml:"mesh>triangles"`
+    Polylists []primitive `xml:"mesh>polylist"`
+}
+
+type source struct {
+    ID string `xml:"id,attr"`
+    Floats string `xml:"float_array"`
+    Accessor struct {
+        Stride int `xml:"stride,attr"`
+    } `xml:"technique_common>accessor"`
+}
+
+type vertices struct {
+    ID string `xml:"id,attr"`
+    Inputs []input `xml:"input"`
+}
+
+type input struct {
+    Semantic string `xml:"semantic,attr"`
+    Source string `xml:"source,attr"`
+    Offset int `xml:"offset,attr"`
+    Set int `xml:"set,attr"`
+}
+
+type primitive struct {
+    Inputs []input `xml:"input"`
+    VCount string `xml:"vcount"`
+    P string `xml:"p"`
+}
+
+type accessor struct {
+    values []float32
+    stride int
+}
+
+func Read(path string) (Model, error) {
+    model := Model{Format: Format}
+    file, err := os.Open(path)
+    if err != nil { return model, err }
+    defer file.Close()
+
+    doc := document{}
+    err = xml.NewDecoder(file).Decode(&doc)
+    if err != nil { return model, err }
+
+    for _, g := range doc.Geometries {
+        mesh, err := readMesh(g)
+        if err != nil { return model, fmt.Errorf("geometry %s: %s", g.ID, err) }
+        model.Meshes = append(model.Meshes, mesh)
+    }
+    return model, nil
+}
+
+func readMesh(g geometry) (Mesh, error) {
+    name := g.Name
+    if name == "" {
+        name = g.ID
+    }
+    mesh := Mesh{Name: name}
+
+    sources := map[string]accessor{}
+    for _, s := range g.Sources {
+        values, err := parseFloats(s.Floats)
+        if err != nil { return mesh, err }
+        sources[s.ID] = accessor{values, s.Accessor.Stride}
+    }
+
+    vertexIDs := map[string]int{}
+    for _, p := range g.Polylists {
+        triangles, err := triangulate(p)
+        if err != nil { return mesh, err }
+        g.Triangles = append(g.Triangles, triangles)
+    }
+    for _, triangles := range g.Triangles {
+        indices, err := parseInts(triangles.P)
+        if err != nil { return mesh, err }
+
+        var position, normal, uv *input
+        stride := 0
+        for i := range triangles.Inputs {
+            in := &triangles.Inputs[i]
+            if in.Offset < 0 { return mesh, fmt.Errorf("negative input offset %d", in.Offset) }
+            if in.Offset + 1 > stride {
+                stride = in.Offset + 1
+            }
+            switch in.Semantic {
+            case "VERTEX":
+                position = in
+            case "NORMAL":
+                normal = in
+            case "TEXCOORD":
+                if uv == nil || in.Set < uv.Set {
+                    uv = in
+                }
+            }
+        }
+        if position == nil { return mesh, errors.New("missing VERTEX input") }
+        positions, err := vertexSource(g.Vertices, sources, position.Source)
+        if err != nil { return mesh, err }
+
+        for i := 0; i + stride <= len(indices); i += stride {
+            vertex := indices[i:i+stride]
+            key := fmt.Sprint(vertex)
+            id, found := vertexIDs[key]
+            if !found {
+                id = len(vertexIDs)
+                vertexIDs[key] = id
+                mesh.Positions, err = appendElement(mesh.Positions, positions, vertex[position.Offset], 3)
+                if err != nil { return mesh, err }
+                if normal != nil {
+                    mesh.Normals, err = appendSource(mesh.Normals, sources, normal, vertex, 3)
+                    if err != nil { return mesh, err }
+                }
+                if uv != nil {
+                    mesh.UVs, err = appendSource(mesh.UVs, sources, uv, vertex, 2)
+                    if err != nil { return mesh, err }
+                }
+            }
+            mesh.Indices = append(mesh.Indices, id)
+        }
+    }
+    return mesh, nil
+}
+
+func vertexSource(v vertices, sources map[string]accessor, ref string) (accessor, error) {
+    id := strings.TrimPrefix(ref, "#")
+    if id == v.ID {
+        for _, in := range v.Inputs {
+            if in.Semantic == "POSITION" {
+                id = strings.TrimPrefix(in.Source, "#")
+            }
+        }
+    }
+    a, found := sources[id]
+    if !found { return a, fmt.Errorf("unknown source %s", ref) }
+    return a, nil
+}
+
+func appendSource(values []float32, sources map[string]accessor, in *input, vertex []int, size int) ([]float32, error) {
+    a, found := sources[strings.TrimPrefix(in.Source, "#")]
+    if !found { return values, fmt.Errorf("unknown source %s", in.Source) }
+    return appendElement(values, a, vertex[in.Offset], size)
+}
+
+func appendElement(values []float32, a accessor, index int, size int) ([]float32, error) {
+    stride := a.stride
+    if stride == 0 {
+        stride = size
+    }
+    start := index * stride
+    if index < 0 || start + size > len(a.values) {
+        return values, fmt.Errorf("index %d out of range", index)
+    }
+    return append(values, a.values[start:start+size]...), nil
+}
+
+func triangulate(p primitive) (primitive, error) {
+    counts, err := parseInts(p.VCount)
+    if err != nil { return p, err }
+    indices, err := parseInts(p.P)
+    if err != nil { return p, err }
+    stride := 0
+    for _, in := range p.Inputs {
+        if in.Offset < 0 { return p, fmt.Errorf("negative input offset %d", in.Offset) }
+        if in.Offset + 1 > stride {
+            stride = in.Offset + 1
+        }
+    }
+    fan := []string{}
+    start := 0
+    for _, count := range counts {
+        if count < 3 { return p, fmt.Errorf("polylist vcount %d is not a polygon", count) }
+        if (start+count)*stride > len(indices) {
+            return p, errors.New("polylist vcount exceeds index data")
+        }
+        polygon := indices[start*stride:(start+count)*stride]
+        for i := 1; i + 1 < count; i++ {
+            for _, corner := range []int{0, i, i + 1} {
+                for _, index := range polygon[corner*stride:(corner+1)*stride] {
+                    fan = append(fan, strconv.Itoa(index))
+                }
+            }
+        }
+        start += count
+    }
+    return primitive{Inputs: p.Inputs, P: strings.Join(fan, " ")}, nil
+}
+
+func parseFloats(text string) ([]float32, error) {
+    fields := strings.Fields(text)
+    values := make([]float32, len(fields))
+    for i, field := range fields {
+        value, err := strconv.ParseFloat(field, 32)
+        if err != nil { return nil, err }
+        values[i] = float32(value)
+    }
+    return values, nil
+}
+
+func parseInts(text string) ([]int, error) {
+    fields := strings.Fields(text)
+    values := make([]int, len(fields))
+    for i, field := range fields {
+        value, err := strconv.Atoi(field)
+        if err != nil { return nil, err }
+        values[i] = value
+    }
+    return values, nil
+}
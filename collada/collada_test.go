@@ -0,0 +1,92 @@
+package collada
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "github.com/GlenKelley/dev/lint"
+)
+
+const quad = `<?xml version="1.0"?>
+<COLLADA xmlns="http://www.collada.org/2005/11/COLLADASchema" version="1.4.1">
+  <library_geometries>
+    <geometry id="quad-mesh" name="Quad">
+      <mesh>
+        <source id="quad-positions">
+          <float_array count="12">0 0 0 1 0 0 1 1 0 0 1 0</float_array>
+          <technique_common><accessor stride="3"/></technique_common>
+        </source>
+        <source id="quad-normals">
+          <float_array count="3">0 0 1</float_array>
+          <technique_common><accessor stride="3"/></technique_common>
+        </source>
+        <vertices id="quad-vertices">
+          <input semantic="POSITION" source="#quad-positions"/>
+        </vertices>
+        <polylist count="1">
+          <input semantic="VERTEX" source="#quad-vertices" offset="0"/>
+          <input semantic="NORMAL" source="#quad-normals" offset="1"/>
+          <vcount>VCOUNT</vcount>
+          <p>0 0 1 0 2 0 3 0</p>
+        </polylist>
+      </mesh>
+    </geometry>
+  </library_geometries>
+</COLLADA>`
+
+func readQuad(t *testing.T, vcount string) (Model, error) {
+    dir, err := ioutil.TempDir("", "collada-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(dir)
+    path := filepath.Join(dir, "quad.dae")
+    err = ioutil.WriteFile(path, []byte(strings.Replace(quad, "VCOUNT", vcount, 1)), 0644)
+    if err != nil { t.Fatal(err) }
+    return Read(path)
+}
+
+func TestReadPolylist(t *testing.T) {
+    model, err := readQuad(t, "4")
+    if err != nil { t.Fatal(err) }
+    if len(model.Meshes) != 1 { t.Fatalf("%d meshes, want 1", len(model.Meshes)) }
+    mesh := model.Meshes[0]
+    if mesh.Name != "Quad" {
+        t.Errorf("name %q, want Quad", mesh.Name)
+    }
+    if want := []int{0, 1, 2, 0, 2, 3}; !reflect.DeepEqual(mesh.Indices, want) {
+        t.Errorf("indices %v, want %v", mesh.Indices, want)
+    }
+    if want := []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}; !reflect.DeepEqual(mesh.Positions, want) {
+        t.Errorf("positions %v, want %v", mesh.Positions, want)
+    }
+    if len(mesh.Normals) != len(mesh.Positions) {
+        t.Errorf("%d normals for %d positions", len(mesh.Normals), len(mesh.Positions))
+    }
+
+    schema, err := ioutil.ReadFile("mesh.schema.json")
+    if err != nil { t.Fatal(err) }
+    content, err := json.Marshal(model)
+    if err != nil { t.Fatal(err) }
+    if problems := lint.Schema(content, schema); len(problems) > 0 {
+        t.Errorf("model does not match mesh.schema.json: %v", problems)
+    }
+}
+
+func TestReadInvalidVCount(t *testing.T) {
+    tests := map[string]string{
+        "-1": "polylist vcount -1 is not a polygon",
+        "0": "polylist vcount 0 is not a polygon",
+        "2 2": "polylist vcount 2 is not a polygon",
+        "5": "polylist vcount exceeds index data",
+        "x": "invalid syntax",
+    }
+    for vcount, want := range tests {
+        _, err := readQuad(t, vcount)
+        if err == nil || !strings.Contains(err.Error(), want) {
+            t.Errorf("vcount %q: error %v, want %q", vcount, err, want)
+        }
+    }
+}
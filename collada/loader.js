@@ -0,0 +1,42 @@
+// Loads a model the build converted from a .dae file, e.g. models/cube.json
+// for models/cube.dae. The file follows mesh.schema.json: {"format": "mesh/1",
+// "meshes": [{"name", "positions", "normals", "uvs", "indices"}]}, where each
+// mesh is an indexed triangle list with 3 floats per vertex in positions and
+// normals, 2 in uvs, and 3 indices per triangle. normals and uvs are omitted
+// when the source has none.
+//
+// loadMesh resolves to the meshes with their attributes as typed arrays, ready
+// for gl.bufferData, and vertexCount for sizing draw calls:
+//
+//     loadMesh("models/cube.json").then(function(meshes) {
+//         var mesh = meshes[0];
+//         gl.bindBuffer(gl.ARRAY_BUFFER, positionBuffer);
+//         gl.bufferData(gl.ARRAY_BUFFER, mesh.positions, gl.STATIC_DRAW);
+//         gl.bindBuffer(gl.ELEMENT_ARRAY_BUFFER, indexBuffer);
+//         gl.bufferData(gl.ELEMENT_ARRAY_BUFFER, mesh.indices, gl.STATIC_DRAW);
+//         gl.drawElements(gl.TRIANGLES, mesh.indices.length, mesh.indexType, 0);
+//     });
+//
+// indexType is gl.UNSIGNED_SHORT when every index fits in 16 bits, and
+// otherwise gl.UNSIGNED_INT, which WebGL 1 needs OES_element_index_uint for.
+function loadMesh(url) {
+    return fetch(url).then(function(response) {
+        if (!response.ok) throw new Error(url + ": " + response.status + " " + response.statusText);
+        return response.json();
+    }).then(function(model) {
+        if (model.format !== "mesh/1") throw new Error(url + ": unsupported mesh format " + model.format);
+        return (model.meshes || []).map(function(mesh) {
+            var vertexCount = mesh.positions.length / 3;
+            var small = vertexCount <= 65536;
+            return {
+                name: mesh.name,
+                vertexCount: vertexCount,
+                positions: new Float32Array(mesh.positions),
+                normals: mesh.normals ? new Float32Array(mesh.normals) : null,
+                uvs: mesh.uvs ? new Float32Array(mesh.uvs) : null,
+                indices: small ? new Uint16Array(mesh.indices) : new Uint32Array(mesh.indices),
+                indexType: small ? 0x1403 : 0x1405
+            };
+        });
+    });
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+    "bytes"
+    "io"
+    "os/exec"
+    "strings"
+    "sync"
+)
+
+type Cmd struct {
+    Name string
+    Args []string
+    Dir string
+    Env []string
+    Stdin io.Reader
+    Stdout io.Writer
+    Stderr io.Writer
+}
+
+func New(name string, args ...string) *Cmd {
+    return &Cmd{Name: name, Args: args}
+}
+
+func (c *Cmd) String() string {
+    return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+type Executor interface {
+    Run(c *Cmd) error
+}
+
+func Output(e Executor, c *Cmd) ([]byte, error) {
+    var stdout bytes.Buffer
+    c.Stdout = &stdout
+    err := e.Run(c)
+    return stdout.Bytes(), err
+}
+
+type System struct{}
+
+func (System) Run(c *Cmd) error {
+    cmd := exec.Command(c.Name, c.Args...)
+    cmd.Dir = c.Dir
+    cmd.Env = c.Env
+    cmd.Stdin = c.Stdin
+    cmd.Stdout = c.Stdout
+    cmd.Stderr = c.Stderr
+    return cmd.Run()
+}
+
+// Recorder is an Executor that records commands instead of running them.
+// Respond, when set, is called for each command and can write output or fail.
+type Recorder struct {
+    lock sync.Mutex
+    Calls []Cmd
+    Respond func(c *Cmd) error
+}
+
+func (r *Recorder) Run(c *Cmd) error {
+    r.lock.Lock()
+    r.Calls = append(r.Calls, *c)
+    r.lock.Unlock()
+    if r.Respond == nil { return nil }
+    return r.Respond(c)
+}
+
+func (r *Recorder) Commands() []string {
+    r.lock.Lock()
+    defer r.lock.Unlock()
+    commands := []string{}
+    for i := range r.Calls {
+        commands = append(commands, r.Calls[i].String())
+    }
+    return commands
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "github.com/GlenKelley/dev/git"
+)
+
+const File = "build.json"
+const DefaultCommonBundle = "js/common.bundle.js"
+
+type Config struct {
+    Sites map[string]Site `json:"sites"`
+    Site
+}
+
+type Site struct {
+    Name string `json:"-"`
+    Root string `json:"root"`
+    Output string `json:"output"`
+    Bucket string `json:"bucket"`
+    URL string `json:"url"`
+    Env map[string]string `json:"env"`
+    Handlers map[string]string `json:"handlers"`
+    Plugins map[string]Plugin `json:"plugins"`
+    Pipelines map[string][]string `json:"pipelines"`
+    Aggregates map[string]Plugin `json:"aggregates"`
+    Lint Lint `json:"lint"`
+    Budgets []Budget `json:"budgets"`
+    I18n I18n `json:"i18n"`
+    Hooks Hooks `json:"hooks"`
+    Bundles Bundles `json:"bundles"`
+}
+
+// Plugin is an external command speaking the plugin package's protocol. As a
+// handler it is run for files with its Extensions; as an aggregate it is run
+// once with the Sources and Outputs matching its globs.
+type Plugin struct {
+    Command string `json:"command"`
+    Args []string `json:"args"`
+    Extensions []string `json:"extensions"`
+    Sources []string `json:"sources"`
+    Outputs []string `json:"outputs"`
+    Options map[string]interface{} `json:"options"`
+}
+
+// Lint sets the severity of each lint check, "error" by default, "warning" or
+// "off", and maps build output globs to JSON schemas relative to the site root.
+type Lint struct {
+    Checks map[string]string `json:"checks"`
+    Schemas map[string]string `json:"schemas"`
+}
+
+// Budget limits the size of the build outputs matching Glob, given as sizes
+// like "150KB". Raw and Gzip limit each file; Page limits the served weight
+// of HTML pages together with the files they reference.
+type Budget struct {
+    Glob string `json:"glob"`
+    Raw string `json:"raw"`
+    Gzip string `json:"gzip"`
+    Page string `json:"page"`
+}
+
+// I18n lists the locales to generate a tree of pages for. Default is the
+// locale used for x-default alternate links, the first listed if unset.
+type I18n struct {
+    Default string `json:"default"`
+    Locales []string `json:"locales"`
+}
+
+// Hooks are shell commands run from the site root, Pre before any source is
+// processed and Post once the output is assembled, before it replaces the
+// site output.
+type Hooks struct {
+    Pre []string `json:"pre"`
+    Post []string `json:"post"`
+}
+
+type Bundles struct {
+    Entries []string `json:"entries"`
+    Common string `json:"common"`
+}
+
+func Load(dir string) (Config, error) {
+    config := Config{}
+    bytes, err := ioutil.ReadFile(filepath.Join(dir, File))
+    if err != nil && !os.IsNotExist(err) { return config, err }
+    if err == nil {
+        err = json.Unmarshal(bytes, &config)
+        if err != nil { return config, fmt.Errorf("%s: %s", File, err) }
+    }
+
+    if config.Root == "" {
+        config.Root = "."
+    }
+    if config.Output == "" {
+        config.Output = "~/Sites"
+    }
+    config.Site = config.Site.withDefaults()
+    for name, site := range config.Sites {
+        site.Name = name
+        if site.Root == "" {
+            site.Root = name
+        }
+        if site.Output == "" {
+            site.Output = filepath.Join("~/Sites", name)
+        }
+        config.Sites[name] = site.withDefaults()
+    }
+    return config, nil
+}
+
+func (s Site) withDefaults() Site {
+    s.Output = ExpandHome(s.Output)
+    if s.Bundles.Common == "" {
+        s.Bundles.Common = DefaultCommonBundle
+    }
+    return s
+}
+
+// Select returns the named sites, or every site when names is empty. A config
+// without sites describes a single site at the repository root.
+func (c Config) Select(names []string) ([]Site, error) {
+    if len(c.Sites) == 0 {
+        if len(names) > 0 { return nil, fmt.Errorf("no sites configured in %s", File) }
+        return []Site{c.Site}, nil
+    }
+    if len(names) == 0 {
+        for name, _ := range c.Sites {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+    }
+    sites := []Site{}
+    for _, name := range names {
+        site, found := c.Sites[name]
+        if !found { return nil, fmt.Errorf("unknown site %q", name) }
+        sites = append(sites, site)
+    }
+    return sites, nil
+}
+
+// SourceRoot returns src, or the enclosing git repository, or the working
+// directory when neither is available.
+func SourceRoot(src string) (string, error) {
+    if src != "" {
+        return filepath.Abs(ExpandHome(src))
+    }
+    groot, err := git.GitRoot()
+    if err == nil { return groot, nil }
+    return os.Getwd()
+}
+
+// WithOutput places each site's output under out, directly for the unnamed
+// default site and in a directory per site otherwise.
+func WithOutput(sites []Site, out string) ([]Site, error) {
+    if out == "" { return sites, nil }
+    out, err := filepath.Abs(ExpandHome(out))
+    if err != nil { return nil, err }
+    for i := range sites {
+        sites[i].Output = filepath.Join(out, sites[i].Name)
+    }
+    return sites, nil
+}
+
+func ExpandHome(path string) string {
+    if path == "~" || strings.HasPrefix(path, "~/") {
+        return filepath.Join(os.Getenv("HOME"), path[1:])
+    }
+    return path
+}
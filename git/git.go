@@ -1,15 +1,40 @@
 package git
 
 import (
-   "os/exec"
+   "strconv"
    "strings"
+   "time"
+   "github.com/GlenKelley/dev/command"
 )
 
+var Executor command.Executor = command.System{}
+
 func GitRoot() (string, error) {
-    bytes, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+    bytes, err := command.Output(Executor, command.New("git", "rev-parse", "--show-toplevel"))
     if err == nil {
         groot := strings.TrimSpace(string(bytes))
         return groot, nil
     } 
     return "", err
 }
+
+func HeadCommit(dir string) (string, error) {
+    cmd := command.New("git", "rev-parse", "HEAD")
+    cmd.Dir = dir
+    bytes, err := command.Output(Executor, cmd)
+    if err == nil {
+        commit := strings.TrimSpace(string(bytes))
+        return commit, nil
+    }
+    return "", err
+}
+
+func HeadCommitTime(dir string) (time.Time, error) {
+    cmd := command.New("git", "log", "-1", "--format=%ct")
+    cmd.Dir = dir
+    bytes, err := command.Output(Executor, cmd)
+    if err != nil { return time.Time{}, err }
+    seconds, err := strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+    if err != nil { return time.Time{}, err }
+    return time.Unix(seconds, 0), nil
+}
@@ -0,0 +1,89 @@
+package glsl
+
+import (
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var (
+    includePattern = regexp.MustCompile(`^#include\s+["<]([^">]+)[">]$`)
+    versionPattern = regexp.MustCompile(`^#version\b`)
+)
+
+func Preprocess(path string, defines map[string]string) (string, error) {
+    lines, err := resolveIncludes(path, map[string]bool{})
+    if err != nil { return "", err }
+    return strings.Join(injectDefines(lines, defines), "\n") + "\n", nil
+}
+
+func resolveIncludes(path string, including map[string]bool) ([]string, error) {
+    absPath, err := filepath.Abs(path)
+    if err != nil { return nil, err }
+    if including[absPath] {
+        return nil, fmt.Errorf("%s: recursive #include", path)
+    }
+    including[absPath] = true
+    defer delete(including, absPath)
+
+    bytes, err := ioutil.ReadFile(path)
+    if err != nil { return nil, err }
+
+    lines := []string{}
+    for _, line := range strings.Split(StripComments(string(bytes)), "\n") {
+        line = strings.Join(strings.Fields(line), " ")
+        if line == "" { continue }
+        match := includePattern.FindStringSubmatch(line)
+        if match == nil {
+            lines = append(lines, line)
+            continue
+        }
+        includePath := filepath.Join(filepath.Dir(path), match[1])
+        included, err := resolveIncludes(includePath, including)
+        if err != nil { return nil, fmt.Errorf("%s: %s", path, err) }
+        lines = append(lines, included...)
+    }
+    return lines, nil
+}
+
+func injectDefines(lines []string, defines map[string]string) []string {
+    names := make([]string, 0, len(defines))
+    for name, _ := range defines {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    header := []string{}
+    if len(lines) > 0 && versionPattern.MatchString(lines[0]) {
+        header = append(header, lines[0])
+        lines = lines[1:]
+    }
+    for _, name := range names {
+        header = append(header, strings.TrimSpace("#define " + name + " " + defines[name]))
+    }
+    return append(header, lines...)
+}
+
+func StripComments(source string) string {
+    var out strings.Builder
+    for i := 0; i < len(source); i++ {
+        if strings.HasPrefix(source[i:], "//") {
+            end := strings.Index(source[i:], "\n")
+            if end < 0 { break }
+            i += end - 1
+        } else if strings.HasPrefix(source[i:], "/*") {
+            end := strings.Index(source[i+2:], "*/")
+            if end < 0 { break }
+            comment := source[i:i+2+end+2]
+            out.WriteString(strings.Repeat("\n", strings.Count(comment, "\n")))
+            out.WriteString(" ")
+            i += len(comment) - 1
+        } else {
+            out.WriteByte(source[i])
+        }
+    }
+    return out.String()
+}
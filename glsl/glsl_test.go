@@ -0,0 +1,85 @@
+package glsl
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestStripComments(t *testing.T) {
+    tests := []struct {
+        source string
+        stripped string
+    }{
+        {"a; // b\nc;", "a; \nc;"},
+        {"a; /* b */ c;", "a;   c;"},
+        {"a;\n/* b\nc */ d;", "a;\n\n  d;"},
+        {"a; // end", "a; "},
+        {"a; /* unterminated", "a; "},
+    }
+    for _, test := range tests {
+        stripped := StripComments(test.source)
+        if stripped != test.stripped {
+            t.Errorf("StripComments(%q) = %q, want %q", test.source, stripped, test.stripped)
+        }
+    }
+}
+
+func writeShaders(t *testing.T, files map[string]string) string {
+    dir, err := ioutil.TempDir("", "glsl-test")
+    if err != nil { t.Fatal(err) }
+    for name, content := range files {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        err = os.MkdirAll(filepath.Dir(path), 0755)
+        if err != nil { t.Fatal(err) }
+        err = ioutil.WriteFile(path, []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+    }
+    return dir
+}
+
+func TestPreprocess(t *testing.T) {
+    dir := writeShaders(t, map[string]string{
+        "main.frag": "#version 300 es\n#include \"lib/_light.glsl\"\nvoid main() {   light(); } // done\n",
+        "lib/_light.glsl": "/* lighting */\n#include <_math.glsl>\nvoid light() {}\n",
+        "lib/_math.glsl": "float sq(float x) { return x * x; }\n",
+    })
+    defer os.RemoveAll(dir)
+
+    source, err := Preprocess(filepath.Join(dir, "main.frag"), map[string]string{"ENV_TEST": "1", "DEBUG": ""})
+    if err != nil { t.Fatal(err) }
+    want := strings.Join([]string{
+        "#version 300 es",
+        "#define DEBUG",
+        "#define ENV_TEST 1",
+        "float sq(float x) { return x * x; }",
+        "void light() {}",
+        "void main() { light(); }",
+    }, "\n") + "\n"
+    if source != want {
+        t.Errorf("Preprocess = %q, want %q", source, want)
+    }
+}
+
+func TestPreprocessErrors(t *testing.T) {
+    dir := writeShaders(t, map[string]string{
+        "loop.frag": "#include \"_a.glsl\"\n",
+        "_a.glsl": "#include \"_b.glsl\"\n",
+        "_b.glsl": "#include \"_a.glsl\"\n",
+        "missing.frag": "#include \"_missing.glsl\"\n",
+    })
+    defer os.RemoveAll(dir)
+
+    tests := map[string]string{
+        "loop.frag": "recursive #include",
+        "missing.frag": "no such file",
+    }
+    for file, want := range tests {
+        _, err := Preprocess(filepath.Join(dir, file), nil)
+        if err == nil || !strings.Contains(err.Error(), want) {
+            t.Errorf("%s: error %v, want %q", file, err, want)
+        }
+    }
+}
@@ -0,0 +1,135 @@
+package i18n
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Table maps message keys to translated strings. Nested objects in string
+// table files are flattened into dotted keys, so {"nav": {"home": "Home"}}
+// defines the key "nav.home".
+type Table map[string]string
+
+// Load reads the string table for locale from dir, as <locale>.json,
+// <locale>.yaml or <locale>.yml.
+func Load(dir string, locale string) (Table, error) {
+    for _, ext := range []string{".json", ".yaml", ".yml"} {
+        path := filepath.Join(dir, locale + ext)
+        bs, err := ioutil.ReadFile(path)
+        if os.IsNotExist(err) { continue }
+        if err != nil { return nil, err }
+        table := Table{}
+        if ext == ".json" {
+            var value map[string]interface{}
+            err = json.Unmarshal(bs, &value)
+            if err != nil { return nil, fmt.Errorf("%s: %s", path, err) }
+            err = table.flatten("", value)
+        } else {
+            err = table.parseYAML(bs)
+        }
+        if err != nil { return nil, fmt.Errorf("%s: %s", path, err) }
+        return table, nil
+    }
+    return nil, fmt.Errorf("no string table for locale %s in %s", locale, dir)
+}
+
+func (t Table) flatten(prefix string, value map[string]interface{}) error {
+    for key, v := range value {
+        switch v := v.(type) {
+        case string:
+            t[prefix + key] = v
+        case map[string]interface{}:
+            err := t.flatten(prefix + key + ".", v)
+            if err != nil { return err }
+        default:
+            return fmt.Errorf("%s%s: expected a string or object", prefix, key)
+        }
+    }
+    return nil
+}
+
+// parseYAML reads the subset of YAML used by string tables: nested mappings
+// of plain or quoted strings, with # comments.
+func (t Table) parseYAML(content []byte) error {
+    type level struct {
+        indent int
+        prefix string
+    }
+    levels := []level{level{-1, ""}}
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := scanner.Text()
+        trimmed := strings.TrimSpace(text)
+        if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" { continue }
+        indent := len(text) - len(strings.TrimLeft(text, " "))
+        parts := strings.SplitN(trimmed, ":", 2)
+        if len(parts) != 2 { return fmt.Errorf("line %d: expected key: value", line) }
+        for indent <= levels[len(levels)-1].indent {
+            levels = levels[:len(levels)-1]
+        }
+        key := levels[len(levels)-1].prefix + unquote(strings.TrimSpace(parts[0]))
+        value := strings.TrimSpace(parts[1])
+        if value == "" {
+            levels = append(levels, level{indent, key + "."})
+            continue
+        }
+        if !strings.HasPrefix(value, "\"") && !strings.HasPrefix(value, "'") {
+            if i := strings.Index(value, " #"); i >= 0 {
+                value = strings.TrimSpace(value[:i])
+            }
+        }
+        t[key] = unquote(value)
+    }
+    return scanner.Err()
+}
+
+func unquote(value string) string {
+    if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+        if value[0] == '"' {
+            var s string
+            if json.Unmarshal([]byte(value), &s) == nil { return s }
+        }
+        return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+    }
+    return value
+}
+
+// Translator looks up keys for one locale, remembering the keys it could not
+// find.
+type Translator struct {
+    Locale string
+    table Table
+    missing map[string]bool
+}
+
+func NewTranslator(locale string, table Table) *Translator {
+    return &Translator{locale, table, map[string]bool{}}
+}
+
+// Translate returns the string for key, or the key itself if it is missing.
+func (t *Translator) Translate(key string) string {
+    value, found := t.table[key]
+    if !found {
+        t.missing[key] = true
+        return key
+    }
+    return value
+}
+
+func (t *Translator) Missing() []string {
+    keys := []string{}
+    for key := range t.missing {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
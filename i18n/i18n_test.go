@@ -0,0 +1,95 @@
+package i18n
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestParseYAML(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        table Table
+        err string
+    }{
+        {"flat", "title: Welcome\nfooter: 'Made with care'\n", Table{"title": "Welcome", "footer": "Made with care"}, ""},
+        {"nested", "---\nnav:\n  home: Home\n  about:\n    title: About us\nfooter: x\n", Table{"nav.home": "Home", "nav.about.title": "About us", "footer": "x"}, ""},
+        {"comments", "# strings\ntitle: Welcome # greeting\nhash: \"a # b\"\n", Table{"title": "Welcome", "hash": "a # b"}, ""},
+        {"quotes", "a: \"line\\nbreak\"\nb: 'it''s'\nc: \"\u00dcber uns\"\n", Table{"a": "line\nbreak", "b": "it's", "c": "\u00dcber uns"}, ""},
+        {"colon in value", "time: \"12:00\"\nurl: http://example.com\n", Table{"time": "12:00", "url": "http://example.com"}, ""},
+        {"dedent", "a:\n  b:\n    c: 1\n  d: 2\ne: 3\n", Table{"a.b.c": "1", "a.d": "2", "e": "3"}, ""},
+        {"not a mapping", "title: Welcome\n- item\n", nil, "line 2: expected key: value"},
+    }
+    for _, test := range tests {
+        table := Table{}
+        err := table.parseYAML([]byte(test.content))
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err {
+            t.Errorf("%s: error %q, want %q", test.name, message, test.err)
+            continue
+        }
+        if err == nil && !reflect.DeepEqual(table, test.table) {
+            t.Errorf("%s: table %v, want %v", test.name, table, test.table)
+        }
+    }
+}
+
+func TestLoad(t *testing.T) {
+    dir, err := ioutil.TempDir("", "i18n-test")
+    if err != nil { t.Fatal(err) }
+    defer os.RemoveAll(dir)
+    files := map[string]string{
+        "en.json": `{"title": "Welcome", "nav": {"about": "About"}}`,
+        "de.yml": "title: Willkommen\n",
+        "fr.json": `{"count": 3}`,
+        "es.json": `{`,
+    }
+    for name, content := range files {
+        err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+    }
+
+    tests := []struct {
+        locale string
+        table Table
+        err string
+    }{
+        {"en", Table{"title": "Welcome", "nav.about": "About"}, ""},
+        {"de", Table{"title": "Willkommen"}, ""},
+        {"fr", nil, "count: expected a string or object"},
+        {"es", nil, "unexpected end of JSON input"},
+        {"it", nil, "no string table for locale it"},
+    }
+    for _, test := range tests {
+        table, err := Load(dir, test.locale)
+        if test.err != "" {
+            if err == nil || !strings.Contains(err.Error(), test.err) {
+                t.Errorf("%s: error %v, want %q", test.locale, err, test.err)
+            }
+            continue
+        }
+        if err != nil || !reflect.DeepEqual(table, test.table) {
+            t.Errorf("%s: %v, %v, want %v", test.locale, table, err, test.table)
+        }
+    }
+}
+
+func TestTranslator(t *testing.T) {
+    translator := NewTranslator("de", Table{"title": "Willkommen"})
+    for key, want := range map[string]string{"title": "Willkommen", "nav.home": "nav.home", "footer": "footer"} {
+        if value := translator.Translate(key); value != want {
+            t.Errorf("Translate(%q) = %q, want %q", key, value, want)
+        }
+    }
+    translator.Translate("footer")
+    if missing := translator.Missing(); !reflect.DeepEqual(missing, []string{"footer", "nav.home"}) {
+        t.Errorf("Missing() = %v", missing)
+    }
+}
@@ -0,0 +1,61 @@
+package linkcheck
+
+import (
+    "bytes"
+    neturl "net/url"
+    "path"
+    "regexp"
+    "strings"
+)
+
+var (
+    htmlPattern = regexp.MustCompile(`(?i)\s(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>'"]+))`)
+    cssPattern = regexp.MustCompile(`(?i)(?:url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]+))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)'))`)
+    schemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+type Reference struct {
+    File string
+    Line int
+    URL string
+}
+
+func Extract(file string, content []byte) []Reference {
+    pattern := htmlPattern
+    if path.Ext(file) == ".css" {
+        pattern = cssPattern
+    }
+    refs := []Reference{}
+    for _, match := range pattern.FindAllSubmatchIndex(content, -1) {
+        for i := 2; i < len(match); i += 2 {
+            if match[i] < 0 { continue }
+            line := bytes.Count(content[:match[i]], []byte("\n")) + 1
+            refs = append(refs, Reference{file, line, string(content[match[i]:match[i+1]])})
+            break
+        }
+    }
+    return refs
+}
+
+func IsInternal(url string) bool {
+    if url == "" || strings.HasPrefix(url, "#") || strings.HasPrefix(url, "//") { return false }
+    return !schemePattern.MatchString(url)
+}
+
+// Resolve returns the item path that url refers to from file, both relative to
+// the site root. Percent-encoded characters in url are decoded.
+func Resolve(file string, url string) string {
+    url = strings.SplitN(strings.SplitN(url, "#", 2)[0], "?", 2)[0]
+    if url == "" { return file }
+    if unescaped, err := neturl.PathUnescape(url); err == nil {
+        url = unescaped
+    }
+    key := path.Join(path.Dir(file), url)
+    if strings.HasPrefix(url, "/") {
+        key = path.Clean(url[1:])
+    }
+    if strings.HasSuffix(url, "/") || key == "." {
+        key = path.Join(key, "index")
+    }
+    return key
+}
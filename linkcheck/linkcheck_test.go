@@ -0,0 +1,68 @@
+package linkcheck
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestExtract(t *testing.T) {
+    tests := []struct {
+        file string
+        content string
+        refs []Reference
+    }{
+        {"a.html", "<a href=\"b.html\">\n<img src='c.png'><a href=d>", []Reference{{"a.html", 1, "b.html"}, {"a.html", 2, "c.png"}, {"a.html", 2, "d"}}},
+        {"a.html", "<p>href=\"x\"</p>", []Reference{}},
+        {"a.css", "@import \"b.css\";\na{background:url( 'c.png' )}\nb{background:url(d.png)}", []Reference{{"a.css", 1, "b.css"}, {"a.css", 2, "c.png"}, {"a.css", 3, "d.png"}}},
+    }
+    for _, test := range tests {
+        refs := Extract(test.file, []byte(test.content))
+        if !reflect.DeepEqual(refs, test.refs) {
+            t.Errorf("Extract(%q, %q) = %v, want %v", test.file, test.content, refs, test.refs)
+        }
+    }
+}
+
+func TestIsInternal(t *testing.T) {
+    tests := map[string]bool{
+        "a.html": true,
+        "/a/b": true,
+        "../a": true,
+        "": false,
+        "#top": false,
+        "//cdn.example.com/a.js": false,
+        "https://example.com": false,
+        "mailto:a@example.com": false,
+    }
+    for url, internal := range tests {
+        if IsInternal(url) != internal {
+            t.Errorf("IsInternal(%q) = %v, want %v", url, !internal, internal)
+        }
+    }
+}
+
+func TestResolve(t *testing.T) {
+    tests := []struct {
+        file string
+        url string
+        key string
+    }{
+        {"blog/post", "other", "blog/other"},
+        {"blog/post", "../about", "about"},
+        {"blog/post", "/img/a.png", "img/a.png"},
+        {"blog/post", "/", "index"},
+        {"blog/post", "./", "blog/index"},
+        {"blog/post", "#top", "blog/post"},
+        {"blog/post", "?page=2", "blog/post"},
+        {"blog/post", "a.png?v=1#x", "blog/a.png"},
+        {"blog/post", "a%20b.png", "blog/a b.png"},
+        {"blog/post", "/caf%C3%A9/", "café/index"},
+        {"blog/post", "100%.png", "blog/100%.png"},
+    }
+    for _, test := range tests {
+        key := Resolve(test.file, test.url)
+        if key != test.key {
+            t.Errorf("Resolve(%q, %q) = %q, want %q", test.file, test.url, key, test.key)
+        }
+    }
+}
@@ -0,0 +1,91 @@
+package lint
+
+import (
+    "bytes"
+    "fmt"
+)
+
+var closers = map[byte]byte { '}': '{', ')': '(', ']': '[' }
+
+// CSS checks that comments, strings and brackets are terminated and that
+// declarations have a property and value.
+func CSS(content []byte) []Problem {
+    problems := []Problem{}
+    type open struct {
+        c byte
+        line int
+    }
+    stack := []open{}
+    statement := 0
+    lines := newLineCounter(content)
+    for i := 0; i < len(content); i++ {
+        c := content[i]
+        line := lines.at(i)
+        switch c {
+        case '/':
+            if i + 1 < len(content) && content[i+1] == '*' {
+                end := bytes.Index(content[i+2:], []byte("*/"))
+                if end < 0 { return append(problems, Problem{line, "unterminated comment"}) }
+                i += 2 + end + 1
+            }
+        case '"', '\'':
+            end := stringEnd(content, i)
+            if end < 0 { return append(problems, Problem{line, "unterminated string"}) }
+            i = end
+        case '{', '(', '[':
+            stack = append(stack, open{c, line})
+            if c == '{' {
+                statement = i + 1
+            }
+        case '}', ')', ']':
+            if len(stack) == 0 || stack[len(stack)-1].c != closers[c] {
+                return append(problems, Problem{line, fmt.Sprintf("unexpected %c", c)})
+            }
+            stack = stack[:len(stack)-1]
+            if c == '}' {
+                statement = i + 1
+            }
+        case ';':
+            if len(stack) == 0 {
+                statement = i + 1
+                continue
+            }
+            if stack[len(stack)-1].c != '{' { continue }
+            declaration := bytes.TrimSpace(stripComments(content[statement:i]))
+            if len(declaration) > 0 && declaration[0] != '@' && bytes.IndexByte(declaration, ':') < 1 {
+                problems = append(problems, Problem{line, fmt.Sprintf("expected a property and value, found %q", declaration)})
+            }
+            statement = i + 1
+        }
+    }
+    for _, o := range stack {
+        problems = append(problems, Problem{o.line, fmt.Sprintf("unclosed %c", o.c)})
+    }
+    return problems
+}
+
+// stringEnd returns the index of the quote closing the string that starts at
+// start, skipping escaped characters, or -1 if the line ends first.
+func stringEnd(content []byte, start int) int {
+    for i := start + 1; i < len(content); i++ {
+        switch content[i] {
+        case '\\':
+            i++
+        case '\n':
+            return -1
+        case content[start]:
+            return i
+        }
+    }
+    return -1
+}
+
+func stripComments(content []byte) []byte {
+    for {
+        start := bytes.Index(content, []byte("/*"))
+        if start < 0 { return content }
+        end := bytes.Index(content[start:], []byte("*/"))
+        if end < 0 { return content[:start] }
+        content = append(append([]byte{}, content[:start]...), content[start+end+2:]...)
+    }
+}
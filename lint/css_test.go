@@ -0,0 +1,32 @@
+package lint
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestCSS(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        problems []Problem
+    }{
+        {"declarations", "a{color:red;margin:0}", []Problem{}},
+        {"semicolon in url", "a{background:url(data:image/png;base64,AAA)}", []Problem{}},
+        {"escaped quote", `a{font-family:"x\"y"}`, []Problem{}},
+        {"escaped backslash", `a{content:"\\";color:red}`, []Problem{}},
+        {"top level at-rule", "@import 'a.css';\na{color:red}", []Problem{}},
+        {"missing value", "a{\ncolor;\n}", []Problem{{2, `expected a property and value, found "color"`}}},
+        {"unterminated string", "a{content:\"x\n}", []Problem{{1, "unterminated string"}}},
+        {"escape before newline", "a{content:\"x\\", []Problem{{1, "unterminated string"}}},
+        {"unclosed block", "a{color:red;", []Problem{{1, "unclosed {"}}},
+        {"unexpected close", "a{color:red;)}", []Problem{{1, "unexpected )"}}},
+        {"unterminated comment", "/* a{}", []Problem{{1, "unterminated comment"}}},
+    }
+    for _, test := range tests {
+        problems := CSS([]byte(test.content))
+        if !reflect.DeepEqual(problems, test.problems) {
+            t.Errorf("%s: CSS(%q) = %v, want %v", test.name, test.content, problems, test.problems)
+        }
+    }
+}
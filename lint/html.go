@@ -0,0 +1,127 @@
+package lint
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+)
+
+var voidElements = map[string]bool {
+    "area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+    "input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEnd elements may be closed implicitly by their parent or a sibling.
+var optionalEnd = map[string]bool {
+    "html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+    "option": true, "optgroup": true, "tr": true, "td": true, "th": true, "thead": true,
+    "tbody": true, "tfoot": true, "colgroup": true, "caption": true, "rp": true, "rt": true,
+}
+
+var rawTextElements = map[string]bool {
+    "script": true, "style": true, "textarea": true, "title": true,
+}
+
+type openElement struct {
+    name string
+    line int
+}
+
+// HTML checks that every element is closed in the order it was opened,
+// allowing void elements and elements with optional end tags.
+func HTML(content []byte) []Problem {
+    problems := []Problem{}
+    stack := []openElement{}
+    lines := newLineCounter(content)
+    for i := 0; i < len(content); i++ {
+        if content[i] != '<' { continue }
+        line := lines.at(i)
+        rest := content[i:]
+        switch {
+        case bytes.HasPrefix(rest, []byte("<!--")):
+            end := bytes.Index(rest[4:], []byte("-->"))
+            if end < 0 { return append(problems, Problem{line, "unterminated comment"}) }
+            i += 4 + end + 2
+            continue
+        case bytes.HasPrefix(rest, []byte("<!")) || bytes.HasPrefix(rest, []byte("<?")):
+            end := bytes.IndexByte(rest, '>')
+            if end < 0 { return append(problems, Problem{line, "unterminated declaration"}) }
+            i += end
+            continue
+        }
+
+        closing := len(rest) > 1 && rest[1] == '/'
+        start := 1
+        if closing {
+            start = 2
+        }
+        name := tagName(rest[start:])
+        if name == "" { continue }
+        end, selfClosing, err := tagEnd(rest)
+        if err != "" { return append(problems, Problem{line, err}) }
+        i += end
+
+        if closing {
+            j := len(stack) - 1
+            for j >= 0 && stack[j].name != name {
+                j--
+            }
+            if j < 0 {
+                problems = append(problems, Problem{line, fmt.Sprintf("unexpected </%s>", name)})
+                continue
+            }
+            for _, open := range stack[j+1:] {
+                if !optionalEnd[open.name] {
+                    problems = append(problems, Problem{open.line, fmt.Sprintf("<%s> is not closed before </%s> on line %d", open.name, name, line)})
+                }
+            }
+            stack = stack[:j]
+            continue
+        }
+        if voidElements[name] || selfClosing { continue }
+        stack = append(stack, openElement{name, line})
+        if rawTextElements[name] {
+            body := bytes.Index(bytes.ToLower(content[i+1:]), []byte("</" + name))
+            if body < 0 { return append(problems, Problem{line, fmt.Sprintf("<%s> is never closed", name)}) }
+            i += body
+        }
+    }
+    for _, open := range stack {
+        if !optionalEnd[open.name] {
+            problems = append(problems, Problem{open.line, fmt.Sprintf("<%s> is never closed", open.name)})
+        }
+    }
+    return problems
+}
+
+func tagName(content []byte) string {
+    end := 0
+    for end < len(content) && isNameByte(content[end], end == 0) {
+        end++
+    }
+    return strings.ToLower(string(content[:end]))
+}
+
+func isNameByte(c byte, first bool) bool {
+    letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+    if first { return letter }
+    return letter || (c >= '0' && c <= '9') || c == '-' || c == ':'
+}
+
+// tagEnd returns the offset of the '>' ending the tag at the start of content,
+// skipping over quoted attribute values.
+func tagEnd(content []byte) (int, bool, string) {
+    for i := 1; i < len(content); i++ {
+        switch content[i] {
+        case '"', '\'':
+            end := bytes.IndexByte(content[i+1:], content[i])
+            if end < 0 { return 0, false, "unterminated attribute value" }
+            i += end + 1
+        case '<':
+            return 0, false, "unterminated tag"
+        case '>':
+            return i, content[i-1] == '/', ""
+        }
+    }
+    return 0, false, "unterminated tag"
+}
@@ -0,0 +1,33 @@
+package lint
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestHTML(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        problems []Problem
+    }{
+        {"document", "<!DOCTYPE html>\n<html><head><title>a < b</title></head>\n<body><p>x<br><img src=\"a.png\"/></p></body></html>", []Problem{}},
+        {"optional end tags", "<ul><li>a<li>b</ul><table><tr><td>1<td>2</table>", []Problem{}},
+        {"raw text", "<script>if (a < b) { document.write('</div>') }</script><style>a>b{}</style>", []Problem{}},
+        {"comment", "<!-- <div> -->\n<p>", []Problem{}},
+        {"quoted attribute", "<a title=\"x > y\" href='/'>a</a>", []Problem{}},
+        {"unclosed", "<div>\n<span>a</div>", []Problem{{2, "<span> is not closed before </div> on line 2"}}},
+        {"never closed", "<div>\n<section>", []Problem{{1, "<div> is never closed"}, {2, "<section> is never closed"}}},
+        {"unexpected close", "<p>a</p>\n</div>", []Problem{{2, "unexpected </div>"}}},
+        {"unterminated comment", "<p>\n<!-- a", []Problem{{2, "unterminated comment"}}},
+        {"unterminated attribute", "<a href=\"x>a</a>", []Problem{{1, "unterminated attribute value"}}},
+        {"unterminated tag", "<div\n<p>", []Problem{{1, "unterminated tag"}}},
+        {"unclosed script", "<script>\nvar a;", []Problem{{1, "<script> is never closed"}}},
+    }
+    for _, test := range tests {
+        problems := HTML([]byte(test.content))
+        if !reflect.DeepEqual(problems, test.problems) {
+            t.Errorf("%s: HTML(%q) = %v, want %v", test.name, test.content, problems, test.problems)
+        }
+    }
+}
@@ -0,0 +1,97 @@
+package lint
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "io"
+)
+
+// Problem is a defect found in a file. Line is 0 when the problem is not
+// tied to a particular line.
+type Problem struct {
+    Line int
+    Message string
+}
+
+// Checks maps check names to the extensions of the build outputs they apply
+// to. The schema check only applies to files with a configured schema.
+var Checks = map[string][]string {
+    "json": []string{".json"},
+    "schema": []string{".json"},
+    "html": []string{".html"},
+    "css": []string{".css"},
+    "svg": []string{".svg"},
+}
+
+func JSON(content []byte) []Problem {
+    var value interface{}
+    err := json.Unmarshal(content, &value)
+    if err == nil { return nil }
+    if syntax, ok := err.(*json.SyntaxError); ok {
+        return []Problem{Problem{lineAt(content, int(syntax.Offset)), syntax.Error()}}
+    }
+    return []Problem{Problem{0, err.Error()}}
+}
+
+// Schema validates content against a JSON Schema. It supports the type,
+// enum, required, properties, additionalProperties, items, minimum, maximum,
+// minLength, maxLength, minItems and maxItems keywords. Content that does
+// not parse is left to the JSON check.
+func Schema(content []byte, schema []byte) []Problem {
+    var value, rules interface{}
+    err := json.Unmarshal(content, &value)
+    if err != nil { return nil }
+    err = json.Unmarshal(schema, &rules)
+    if err != nil { return []Problem{Problem{0, "invalid schema: " + err.Error()}} }
+    problems := []Problem{}
+    for _, message := range validate(rules, value, "") {
+        problems = append(problems, Problem{0, message})
+    }
+    return problems
+}
+
+func SVG(content []byte) []Problem {
+    decoder := xml.NewDecoder(bytes.NewReader(content))
+    for {
+        _, err := decoder.Token()
+        if err == io.EOF { return nil }
+        if err != nil {
+            if syntax, ok := err.(*xml.SyntaxError); ok {
+                return []Problem{Problem{syntax.Line, syntax.Msg}}
+            }
+            return []Problem{Problem{0, err.Error()}}
+        }
+    }
+}
+
+// lineCounter finds the line of offsets in content that only move forward,
+// counting newlines from the last offset rather than the start.
+type lineCounter struct {
+    content []byte
+    offset int
+    line int
+}
+
+func newLineCounter(content []byte) *lineCounter {
+    return &lineCounter{content, 0, 1}
+}
+
+func (l *lineCounter) at(offset int) int {
+    if offset > len(l.content) {
+        offset = len(l.content)
+    }
+    if offset < l.offset {
+        l.offset, l.line = 0, 1
+    }
+    l.line += bytes.Count(l.content[l.offset:offset], []byte("\n"))
+    l.offset = offset
+    return l.line
+}
+
+func lineAt(content []byte, offset int) int {
+    if offset > len(content) {
+        offset = len(content)
+    }
+    return bytes.Count(content[:offset], []byte("\n")) + 1
+}
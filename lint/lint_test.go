@@ -0,0 +1,81 @@
+package lint
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestJSON(t *testing.T) {
+    tests := []struct {
+        content string
+        problems []Problem
+    }{
+        {`{"a": [1, 2]}`, nil},
+        {"{\n\"a\": 1,\n}", []Problem{{3, "invalid character '}' looking for beginning of object key string"}}},
+        {"[1,\n2", []Problem{{2, "unexpected end of JSON input"}}},
+    }
+    for _, test := range tests {
+        problems := JSON([]byte(test.content))
+        if !reflect.DeepEqual(problems, test.problems) {
+            t.Errorf("JSON(%q) = %v, want %v", test.content, problems, test.problems)
+        }
+    }
+}
+
+func TestSchema(t *testing.T) {
+    schema := `{
+        "type": "object",
+        "required": ["name", "tags"],
+        "properties": {
+            "name": {"type": "string", "minLength": 1},
+            "kind": {"enum": ["page", "post"]},
+            "tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+            "weight": {"type": "integer", "minimum": 0},
+            "extra": {"type": "object", "additionalProperties": {"type": "number"}}
+        },
+        "additionalProperties": false
+    }`
+    tests := []struct {
+        content string
+        problems []Problem
+    }{
+        {`{"name": "a", "tags": ["x"], "kind": "post", "weight": 2, "extra": {"b": 1.5}}`, []Problem{}},
+        {`{"tags": []}`, []Problem{{0, `/: missing required property "name"`}}},
+        {`{"name": "", "tags": []}`, []Problem{{0, "/name: 0 is below minLength 1"}}},
+        {`{"name": "a", "tags": ["x", 1, "z"]}`, []Problem{{0, "/tags: 3 is above maxItems 2"}, {0, "/tags/1: expected string, found integer"}}},
+        {`{"name": "a", "tags": [], "kind": "draft"}`, []Problem{{0, "/kind: value is not one of [page post]"}}},
+        {`{"name": "a", "tags": [], "weight": 1.5}`, []Problem{{0, "/weight: expected integer, found number"}}},
+        {`{"name": "a", "tags": [], "extra": {"b": "c"}}`, []Problem{{0, "/extra/b: expected number, found string"}}},
+        {`{"name": "a", "tags": [], "other": 1}`, []Problem{{0, `/: unexpected property "other"`}}},
+        {`[1]`, []Problem{{0, "/: expected object, found array"}}},
+        {`{`, nil},
+    }
+    for _, test := range tests {
+        problems := Schema([]byte(test.content), []byte(schema))
+        if !reflect.DeepEqual(problems, test.problems) {
+            t.Errorf("Schema(%s) = %v, want %v", test.content, problems, test.problems)
+        }
+    }
+
+    problems := Schema([]byte(`{}`), []byte(`{`))
+    if len(problems) != 1 || problems[0].Message != "invalid schema: unexpected end of JSON input" {
+        t.Errorf("invalid schema: %v", problems)
+    }
+}
+
+func TestSVG(t *testing.T) {
+    tests := []struct {
+        content string
+        problems []Problem
+    }{
+        {`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1"/></svg>`, nil},
+        {"<svg>\n<g>\n</svg>", []Problem{{3, "element <g> closed by </svg>"}}},
+        {"<svg>\n<rect width=1/>\n</svg>", []Problem{{2, "unquoted or missing attribute value in element"}}},
+    }
+    for _, test := range tests {
+        problems := SVG([]byte(test.content))
+        if !reflect.DeepEqual(problems, test.problems) {
+            t.Errorf("SVG(%q) = %v, want %v", test.content, problems, test.problems)
+        }
+    }
+}
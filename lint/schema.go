@@ -0,0 +1,108 @@
+package lint
+
+import (
+    "fmt"
+    "reflect"
+    "sort"
+)
+
+func validate(schema interface{}, value interface{}, pointer string) []string {
+    rules, ok := schema.(map[string]interface{})
+    if !ok { return nil }
+    location := pointer
+    if location == "" {
+        location = "/"
+    }
+    messages := []string{}
+    fail := func(format string, args ...interface{}) {
+        messages = append(messages, location + ": " + fmt.Sprintf(format, args...))
+    }
+
+    if expected, found := rules["type"]; found && !matchesType(expected, value) {
+        fail("expected %v, found %s", expected, typeName(value))
+        return messages
+    }
+    if options, found := rules["enum"].([]interface{}); found {
+        matched := false
+        for _, option := range options {
+            matched = matched || reflect.DeepEqual(option, value)
+        }
+        if !matched { fail("value is not one of %v", options) }
+    }
+
+    switch v := value.(type) {
+    case map[string]interface{}:
+        if required, found := rules["required"].([]interface{}); found {
+            for _, key := range required {
+                name, _ := key.(string)
+                if _, present := v[name]; !present { fail("missing required property %q", name) }
+            }
+        }
+        properties, _ := rules["properties"].(map[string]interface{})
+        keys := []string{}
+        for key := range v {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+            if property, found := properties[key]; found {
+                messages = append(messages, validate(property, v[key], pointer + "/" + key)...)
+            } else if additional, found := rules["additionalProperties"]; found {
+                if additional == false {
+                    fail("unexpected property %q", key)
+                } else {
+                    messages = append(messages, validate(additional, v[key], pointer + "/" + key)...)
+                }
+            }
+        }
+    case []interface{}:
+        checkBounds(rules, "minItems", "maxItems", float64(len(v)), fail)
+        if items, found := rules["items"]; found {
+            for i, item := range v {
+                messages = append(messages, validate(items, item, fmt.Sprintf("%s/%d", pointer, i))...)
+            }
+        }
+    case string:
+        checkBounds(rules, "minLength", "maxLength", float64(len([]rune(v))), fail)
+    case float64:
+        checkBounds(rules, "minimum", "maximum", v, fail)
+    }
+    return messages
+}
+
+func checkBounds(rules map[string]interface{}, minKey string, maxKey string, n float64, fail func(string, ...interface{})) {
+    if min, found := rules[minKey].(float64); found && n < min {
+        fail("%v is below %s %v", n, minKey, min)
+    }
+    if max, found := rules[maxKey].(float64); found && n > max {
+        fail("%v is above %s %v", n, maxKey, max)
+    }
+}
+
+func matchesType(expected interface{}, value interface{}) bool {
+    if types, ok := expected.([]interface{}); ok {
+        for _, t := range types {
+            if matchesType(t, value) { return true }
+        }
+        return false
+    }
+    name := typeName(value)
+    return expected == name || (expected == "number" && name == "integer")
+}
+
+func typeName(value interface{}) string {
+    switch v := value.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case string:
+        return "string"
+    case float64:
+        if v == float64(int64(v)) { return "integer" }
+        return "number"
+    case []interface{}:
+        return "array"
+    }
+    return "object"
+}
@@ -0,0 +1,187 @@
+package livereload
+
+import (
+    "bytes"
+    "compress/gzip"
+    "fmt"
+    "io/ioutil"
+    "mime"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+    "sync"
+)
+
+// EventsPath is the server-sent events endpoint pages connect to.
+const EventsPath = "/__livereload"
+
+// Events sent to pages. Reload reloads the page, CSS reloads its stylesheets
+// in place and Error shows the data as an overlay until the next good build.
+const (
+    Reload = "reload"
+    CSS = "css"
+    Error = "error"
+)
+
+type Event struct {
+    Type string
+    Data string
+}
+
+// Server serves a build output directory the way the deploy bucket does,
+// injecting a script into HTML pages that listens for build events.
+type Server struct {
+    dir string
+    lock sync.Mutex
+    clients map[chan Event]bool
+    failure string
+}
+
+func NewServer(dir string) *Server {
+    return &Server{dir: dir, clients: map[chan Event]bool{}}
+}
+
+// Send delivers an event to every connected page.
+func (s *Server) Send(event Event) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    s.failure = ""
+    if event.Type == Error {
+        s.failure = event.Data
+    }
+    for client := range s.clients {
+        select {
+        case client <- event:
+        default:
+        }
+    }
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path == EventsPath {
+        s.serveEvents(w, r)
+        return
+    }
+    s.serveFile(w, r)
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+    client := make(chan Event, 4)
+    s.lock.Lock()
+    s.clients[client] = true
+    if s.failure != "" {
+        client <- Event{Error, s.failure}
+    }
+    s.lock.Unlock()
+    defer func() {
+        s.lock.Lock()
+        delete(s.clients, client)
+        s.lock.Unlock()
+    }()
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    flusher.Flush()
+    for {
+        select {
+        case event := <-client:
+            fmt.Fprintf(w, "event: %s\n", event.Type)
+            for _, line := range strings.Split(event.Data, "\n") {
+                fmt.Fprintf(w, "data: %s\n", line)
+            }
+            fmt.Fprint(w, "\n")
+            flusher.Flush()
+        case <-r.Context().Done():
+            return
+        }
+    }
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+    file := path.Clean("/" + r.URL.Path)
+    if strings.HasSuffix(r.URL.Path, "/") {
+        file = path.Join(file, "index")
+    }
+    filePath := filepath.Join(s.dir, filepath.FromSlash(file))
+    if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+        filePath += ".html"
+    }
+    content, err := ioutil.ReadFile(filePath)
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+
+    ext := filepath.Ext(filePath)
+    gzipped := len(content) >= 2 && content[0] == 0x1f && content[1] == 0x8b
+    if ext == ".html" {
+        if gzipped {
+            content, err = gunzip(content)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            gzipped = false
+        }
+        content = inject(content)
+    }
+    if gzipped {
+        w.Header().Set("Content-Encoding", "gzip")
+    }
+    if contentType := mime.TypeByExtension(ext); contentType != "" {
+        w.Header().Set("Content-Type", contentType)
+    }
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Write(content)
+}
+
+func gunzip(content []byte) ([]byte, error) {
+    reader, err := gzip.NewReader(bytes.NewReader(content))
+    if err != nil { return nil, err }
+    defer reader.Close()
+    return ioutil.ReadAll(reader)
+}
+
+func inject(page []byte) []byte {
+    tag := []byte("<script>" + script + "</script>")
+    end := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+    if end < 0 {
+        return append(page, tag...)
+    }
+    return append(append(append([]byte{}, page[:end]...), tag...), page[end:]...)
+}
+
+const script = `(function() {
+    var events = new EventSource("` + EventsPath + `");
+    var overlayId = "__livereload_overlay";
+    function hideOverlay() {
+        var overlay = document.getElementById(overlayId);
+        if (overlay) overlay.parentNode.removeChild(overlay);
+    }
+    events.addEventListener("` + Reload + `", function() { location.reload(); });
+    events.addEventListener("` + CSS + `", function() {
+        hideOverlay();
+        var links = document.querySelectorAll("link[rel=stylesheet]");
+        for (var i = 0; i < links.length; i++) {
+            var link = links[i];
+            var href = link.getAttribute("href").replace(/[?&]livereload=\d+/, "");
+            link.removeAttribute("integrity");
+            link.setAttribute("href", href + (href.indexOf("?") < 0 ? "?" : "&") + "livereload=" + Date.now());
+        }
+    });
+    events.addEventListener("` + Error + `", function(e) {
+        hideOverlay();
+        var overlay = document.createElement("pre");
+        overlay.id = overlayId;
+        overlay.style.cssText = "position:fixed;top:0;left:0;right:0;bottom:0;margin:0;padding:2em;overflow:auto;z-index:2147483647;background:rgba(0,0,0,0.85);color:#f66;font:14px monospace;white-space:pre-wrap";
+        overlay.textContent = e.data;
+        document.body.appendChild(overlay);
+    });
+})();`
@@ -0,0 +1,117 @@
+package lock
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strconv"
+    "strings"
+    "syscall"
+    "time"
+)
+
+type Lock struct {
+    Path string
+    file *os.File
+}
+
+type Holder struct {
+    Pid int
+    Host string
+    Since time.Time
+}
+
+func (h Holder) String() string {
+    return fmt.Sprintf("pid %d on %s since %s", h.Pid, h.Host, h.Since.Format(time.RFC1123))
+}
+
+var errEmpty = errors.New("empty lock file")
+
+const (
+    retries = 20
+    retryDelay = 50 * time.Millisecond
+)
+
+// Acquire takes an exclusive flock on the file at path and records the holder
+// in it. The kernel drops the lock when its holder exits, so a lock file left
+// behind by a crashed process is simply locked again.
+func Acquire(path string) (*Lock, error) {
+    host, err := os.Hostname()
+    if err != nil { return nil, err }
+    for attempt := 0; ; attempt++ {
+        file, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0644)
+        if err != nil { return nil, err }
+        err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX | syscall.LOCK_NB)
+        if err == syscall.EWOULDBLOCK {
+            file.Close()
+            holder, err := ReadHolder(path)
+            if err == nil { return nil, fmt.Errorf("%s is locked by %s", path, holder) }
+            // The holder has not written its details yet or has just released.
+            if (err == errEmpty || os.IsNotExist(err)) && attempt < retries {
+                time.Sleep(retryDelay)
+                continue
+            }
+            return nil, err
+        }
+        if err != nil {
+            file.Close()
+            return nil, err
+        }
+        // The previous holder may have removed the file between our open and
+        // flock, leaving us holding a lock nobody else can see.
+        if !sameFile(file, path) {
+            file.Close()
+            continue
+        }
+        err = file.Truncate(0)
+        if err == nil {
+            _, err = fmt.Fprintf(file, "%d %s %d\n", os.Getpid(), host, time.Now().Unix())
+        }
+        if err != nil {
+            file.Close()
+            return nil, err
+        }
+        return &Lock{path, file}, nil
+    }
+}
+
+// Release removes the lock file while still holding the lock, so that anyone
+// waiting on the old file notices it is gone, and then unlocks it.
+func (l *Lock) Release() error {
+    err := os.Remove(l.Path)
+    closeErr := l.file.Close()
+    if err != nil { return err }
+    return closeErr
+}
+
+func sameFile(file *os.File, path string) bool {
+    opened, err := file.Stat()
+    if err != nil { return false }
+    current, err := os.Stat(path)
+    if err != nil { return false }
+    return os.SameFile(opened, current)
+}
+
+func ReadHolder(path string) (Holder, error) {
+    holder := Holder{}
+    bytes, err := ioutil.ReadFile(path)
+    if err != nil { return holder, err }
+    fields := strings.Fields(string(bytes))
+    if len(fields) == 0 { return holder, errEmpty }
+    if len(fields) != 3 { return holder, fmt.Errorf("invalid lock file %s", path) }
+    holder.Pid, err = strconv.Atoi(fields[0])
+    if err != nil { return holder, err }
+    holder.Host = fields[1]
+    seconds, err := strconv.ParseInt(fields[2], 10, 64)
+    if err != nil { return holder, err }
+    holder.Since = time.Unix(seconds, 0)
+    return holder, nil
+}
+
+func ProcessAlive(pid int) bool {
+    process, err := os.FindProcess(pid)
+    if err != nil { return false }
+    err = process.Signal(syscall.Signal(0))
+    return err == nil || err == syscall.EPERM
+}
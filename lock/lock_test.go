@@ -0,0 +1,94 @@
+package lock
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+)
+
+func tempLockPath(t *testing.T) (string, func()) {
+    dir, err := ioutil.TempDir("", "lock-test")
+    if err != nil { t.Fatal(err) }
+    return filepath.Join(dir, "out.lock"), func() { os.RemoveAll(dir) }
+}
+
+func TestAcquireRelease(t *testing.T) {
+    path, cleanup := tempLockPath(t)
+    defer cleanup()
+
+    l, err := Acquire(path)
+    if err != nil { t.Fatal(err) }
+    holder, err := ReadHolder(path)
+    if err != nil { t.Fatal(err) }
+    if holder.Pid != os.Getpid() {
+        t.Errorf("holder pid %d, want %d", holder.Pid, os.Getpid())
+    }
+
+    _, err = Acquire(path)
+    if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("locked by pid %d", os.Getpid())) {
+        t.Errorf("second Acquire: %v, want locked by this process", err)
+    }
+
+    err = l.Release()
+    if err != nil { t.Fatal(err) }
+    if _, err := os.Stat(path); !os.IsNotExist(err) {
+        t.Errorf("lock file remains after Release: %v", err)
+    }
+    l, err = Acquire(path)
+    if err != nil { t.Fatal(err) }
+    l.Release()
+}
+
+func TestAcquireLeftoverFile(t *testing.T) {
+    path, cleanup := tempLockPath(t)
+    defer cleanup()
+
+    for _, content := range []string{"", "garbage", "1 otherhost 0\n"} {
+        err := ioutil.WriteFile(path, []byte(content), 0644)
+        if err != nil { t.Fatal(err) }
+        l, err := Acquire(path)
+        if err != nil {
+            t.Errorf("Acquire over %q: %s", content, err)
+            continue
+        }
+        l.Release()
+    }
+}
+
+func TestAcquireConcurrent(t *testing.T) {
+    path, cleanup := tempLockPath(t)
+    defer cleanup()
+
+    var group sync.WaitGroup
+    var mutex sync.Mutex
+    held := 0
+    for i := 0; i < 20; i++ {
+        group.Add(1)
+        go func() {
+            defer group.Done()
+            for j := 0; j < 20; j++ {
+                l, err := Acquire(path)
+                if err != nil {
+                    if !strings.Contains(err.Error(), "is locked by") {
+                        t.Errorf("Acquire: %s", err)
+                    }
+                    continue
+                }
+                mutex.Lock()
+                held++
+                if held > 1 { t.Errorf("%d holders", held) }
+                mutex.Unlock()
+
+                mutex.Lock()
+                held--
+                mutex.Unlock()
+                l.Release()
+            }
+        }()
+    }
+    group.Wait()
+}
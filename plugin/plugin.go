@@ -0,0 +1,60 @@
+package plugin
+
+import "strconv"
+
+// Version is sent with every request so plugins can reject requests they
+// do not understand.
+const Version = 1
+
+// Request is written as JSON to a plugin's stdin, once per source file for
+// handlers or once per build for aggregates. Aggregates are given the matching
+// Sources and Outputs relative to SourceRoot and OutputRoot.
+type Request struct {
+    Version int `json:"version"`
+    Source string `json:"source"`
+    Sources []string `json:"sources"`
+    Outputs []string `json:"outputs"`
+    SourceRoot string `json:"sourceRoot"`
+    OutputRoot string `json:"outputRoot"`
+    Env string `json:"env"`
+    Options map[string]interface{} `json:"options"`
+}
+
+// Response is read as JSON from a plugin's stdout. File paths are relative
+// to the request's OutputRoot.
+type Response struct {
+    Files []File `json:"files"`
+    Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type File struct {
+    Path string `json:"path"`
+    Compress bool `json:"compress"`
+}
+
+const (
+    Error = "error"
+    Warning = "warning"
+)
+
+type Diagnostic struct {
+    Severity string `json:"severity"`
+    File string `json:"file"`
+    Line int `json:"line"`
+    Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+    location := d.File
+    if d.Line > 0 {
+        location = location + ":" + strconv.Itoa(d.Line)
+    }
+    if location != "" {
+        location += ": "
+    }
+    severity := d.Severity
+    if severity == "" {
+        severity = Error
+    }
+    return location + severity + ": " + d.Message
+}
@@ -0,0 +1,95 @@
+package redirect
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "strings"
+    "github.com/GlenKelley/dev/linkcheck"
+)
+
+// File is the name of the redirects file at the root of a site. Each line is a
+// source path and a destination separated by whitespace; blank lines and lines
+// starting with # are ignored.
+//
+//     /old-page      /new-page
+//     /blog/         https://blog.example.com/
+const File = "_redirects"
+
+type Redirect struct {
+    From string
+    To string
+    Line int
+}
+
+func Parse(content []byte) ([]Redirect, error) {
+    redirects := []Redirect{}
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" || strings.HasPrefix(text, "#") { continue }
+        fields := strings.Fields(text)
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("%s:%d: expected a source and destination", File, line)
+        }
+        r := Redirect{fields[0], fields[1], line}
+        if !strings.HasPrefix(r.From, "/") {
+            return nil, fmt.Errorf("%s:%d: source %s must be an absolute path", File, line, r.From)
+        }
+        if !strings.HasPrefix(r.To, "/") && !strings.HasPrefix(r.To, "http://") && !strings.HasPrefix(r.To, "https://") {
+            return nil, fmt.Errorf("%s:%d: destination %s must be an absolute path or http url", File, line, r.To)
+        }
+        redirects = append(redirects, r)
+    }
+    return redirects, scanner.Err()
+}
+
+// Key returns the item path of the object that serves the redirect.
+func (r Redirect) Key() string {
+    return linkcheck.Resolve("index", r.From)
+}
+
+// Target returns the item path the redirect points to, or "" if it leaves
+// the site.
+func (r Redirect) Target() string {
+    if strings.Contains(r.To, "//") { return "" }
+    return linkcheck.Resolve("index", r.To)
+}
+
+// Check reports redirects that are duplicated, shadow an existing page, point
+// at a missing page, or loop back on themselves.
+func Check(redirects []Redirect, exists func(key string) bool) []error {
+    errs := []error{}
+    byKey := map[string]Redirect{}
+    for _, r := range redirects {
+        key := r.Key()
+        if previous, found := byKey[key]; found {
+            errs = append(errs, fmt.Errorf("%s:%d: %s is already redirected on line %d", File, r.Line, r.From, previous.Line))
+            continue
+        }
+        byKey[key] = r
+        if exists(key) {
+            errs = append(errs, fmt.Errorf("%s:%d: %s shadows an existing page", File, r.Line, r.From))
+        }
+    }
+    for _, r := range redirects {
+        if byKey[r.Key()].Line != r.Line { continue }
+        target := r.Target()
+        if target == "" { continue }
+        if _, found := byKey[target]; !found && !exists(target) {
+            errs = append(errs, fmt.Errorf("%s:%d: %s redirects to missing page %s", File, r.Line, r.From, r.To))
+            continue
+        }
+        visited := map[string]bool{r.Key(): true}
+        for next, found := byKey[target]; found; next, found = byKey[next.Target()] {
+            if visited[next.Key()] {
+                errs = append(errs, fmt.Errorf("%s:%d: %s redirects in a loop", File, r.Line, r.From))
+                break
+            }
+            visited[next.Key()] = true
+        }
+    }
+    return errs
+}
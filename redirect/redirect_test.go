@@ -0,0 +1,76 @@
+package redirect
+
+import (
+    "fmt"
+    "reflect"
+    "testing"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        content string
+        redirects []Redirect
+        err string
+    }{
+        {"# moved\n\n/old /new\n  /blog/   https://blog.example.com/  \n", []Redirect{{"/old", "/new", 3}, {"/blog/", "https://blog.example.com/", 4}}, ""},
+        {"/old", nil, "_redirects:1: expected a source and destination"},
+        {"/a /b /c", nil, "_redirects:1: expected a source and destination"},
+        {"\nold /new", nil, "_redirects:2: source old must be an absolute path"},
+        {"/old new", nil, "_redirects:1: destination new must be an absolute path or http url"},
+        {"/old ftp://example.com/", nil, "_redirects:1: destination ftp://example.com/ must be an absolute path or http url"},
+    }
+    for _, test := range tests {
+        redirects, err := Parse([]byte(test.content))
+        message := ""
+        if err != nil {
+            message = err.Error()
+        }
+        if message != test.err || (err == nil && !reflect.DeepEqual(redirects, test.redirects)) {
+            t.Errorf("Parse(%q) = %v, %q, want %v, %q", test.content, redirects, message, test.redirects, test.err)
+        }
+    }
+}
+
+func TestKeyAndTarget(t *testing.T) {
+    tests := []struct {
+        r Redirect
+        key string
+        target string
+    }{
+        {Redirect{"/old", "/new", 1}, "old", "new"},
+        {Redirect{"/blog/", "/posts/", 1}, "blog/index", "posts/index"},
+        {Redirect{"/", "/home", 1}, "index", "home"},
+        {Redirect{"/away", "https://example.com/", 1}, "away", ""},
+    }
+    for _, test := range tests {
+        if key, target := test.r.Key(), test.r.Target(); key != test.key || target != test.target {
+            t.Errorf("%v: key %q target %q, want %q %q", test.r, key, target, test.key, test.target)
+        }
+    }
+}
+
+func TestCheck(t *testing.T) {
+    pages := map[string]bool{"index": true, "new": true, "about": true}
+    exists := func(key string) bool { return pages[key] }
+    tests := []struct {
+        content string
+        errs []string
+    }{
+        {"/old /new\n/older /old\n/away https://example.com/", []string{}},
+        {"/old /new\n/old /about", []string{"_redirects:2: /old is already redirected on line 1"}},
+        {"/about /new", []string{"_redirects:1: /about shadows an existing page"}},
+        {"/old /gone", []string{"_redirects:1: /old redirects to missing page /gone"}},
+        {"/a /b\n/b /a", []string{"_redirects:1: /a redirects in a loop", "_redirects:2: /b redirects in a loop"}},
+    }
+    for _, test := range tests {
+        redirects, err := Parse([]byte(test.content))
+        if err != nil { t.Fatal(err) }
+        errs := []string{}
+        for _, err := range Check(redirects, exists) {
+            errs = append(errs, err.Error())
+        }
+        if !reflect.DeepEqual(errs, test.errs) {
+            t.Errorf("Check(%q) = %s, want %s", test.content, fmt.Sprint(errs), fmt.Sprint(test.errs))
+        }
+    }
+}
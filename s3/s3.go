@@ -33,6 +33,7 @@ type S3UploadInfo struct {
     ModTime time.Time
     MD5 string
     ItemPath string
+    RedirectLocation string
 }
 
 type S3Credentials struct {
@@ -68,6 +69,14 @@ func GetCredentials() (S3Credentials, error) {
     return S3Credentials{}, errors.New("invalid aws credentials file")
 }
 
+func ItemPath(relativePath string) string {
+    ext := filepath.Ext(relativePath)
+    if (ext == ".html") {
+        return relativePath[0:len(relativePath)-len(ext)]
+    }
+    return relativePath
+}
+
 func GetS3Info(bucket string, s3ItemPath string) (S3Info, error) {
     s3Path := "http://" + filepath.Join(bucket + awsHost, s3ItemPath)
     s3Info := S3Info{}
@@ -146,10 +155,24 @@ func signRequest(request *http.Request, credentials S3Credentials) error {
 }
 
 func UploadToS3(path string, bucket string, info S3UploadInfo) error {
-    s3Path := "http://" + filepath.Join(bucket + awsHost, info.ItemPath)
-        
     bs, err := ioutil.ReadFile(path)
     if err != nil { return err }
+    return upload(bs, bucket, info)
+}
+
+// UploadRedirect creates an empty object that the bucket's website endpoint
+// serves as a redirect to location.
+func UploadRedirect(bucket string, itemPath string, location string) error {
+    info := S3UploadInfo{}
+    info.Public = true
+    info.ModTime = time.Now()
+    info.ItemPath = itemPath
+    info.RedirectLocation = location
+    return upload(nil, bucket, info)
+}
+
+func upload(bs []byte, bucket string, info S3UploadInfo) error {
+    s3Path := "http://" + filepath.Join(bucket + awsHost, info.ItemPath)
     body := strings.NewReader(string(bs))
     
     t := time.Now()
@@ -179,6 +202,10 @@ func UploadToS3(path string, bucket string, info S3UploadInfo) error {
     if (info.Encoding != "") {
         request.Header.Add("Content-Encoding", info.Encoding)
     }
+
+    if info.RedirectLocation != "" {
+        request.Header.Add("x-amz-website-redirect-location", info.RedirectLocation)
+    }
     
     if info.Public {
         request.Header.Add("x-amz-acl", "public-read")
@@ -0,0 +1,75 @@
+package sri
+
+import (
+    "crypto/sha512"
+    "encoding/base64"
+    "regexp"
+    "strings"
+)
+
+var (
+    scriptPattern = regexp.MustCompile(`(?is)<script\b[^>]*>`)
+    linkPattern = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+    attrPattern = regexp.MustCompile(`(?is)\s([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+    stylesheetPattern = regexp.MustCompile(`(?i)(^|\s)stylesheet(\s|$)`)
+    schemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+func Digest(content []byte) string {
+    sum := sha512.Sum384(content)
+    return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Annotate adds integrity and crossorigin attributes to the script and
+// stylesheet tags in page. Tags that already have an integrity attribute, and
+// external and data: urls, are left alone. read is given the path of a local
+// url, without its query or fragment, and returns its content, or nil for
+// paths that are not part of the build.
+func Annotate(page []byte, read func(url string) ([]byte, error)) ([]byte, error) {
+    var failure error
+    annotate := func(tag []byte, urlAttr string) []byte {
+        attrs := attributes(tag)
+        if _, found := attrs["integrity"]; found { return tag }
+        url, found := attrs[urlAttr]
+        if !found || url == "" || strings.HasPrefix(url, "//") || schemePattern.MatchString(url) { return tag }
+        url = strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0]
+        if url == "" { return tag }
+        content, err := read(url)
+        if err != nil {
+            if failure == nil { failure = err }
+            return tag
+        }
+        if content == nil { return tag }
+
+        extra := ` integrity="` + Digest(content) + `"`
+        if _, found := attrs["crossorigin"]; !found {
+            extra += ` crossorigin="anonymous"`
+        }
+        end := len(tag) - 1
+        if tag[end-1] == '/' {
+            end--
+        }
+        return []byte(string(tag[:end]) + extra + string(tag[end:]))
+    }
+
+    page = scriptPattern.ReplaceAllFunc(page, func(tag []byte) []byte {
+        return annotate(tag, "src")
+    })
+    page = linkPattern.ReplaceAllFunc(page, func(tag []byte) []byte {
+        if !stylesheetPattern.MatchString(attributes(tag)["rel"]) { return tag }
+        return annotate(tag, "href")
+    })
+    return page, failure
+}
+
+func attributes(tag []byte) map[string]string {
+    attrs := map[string]string{}
+    for _, match := range attrPattern.FindAllSubmatch(tag, -1) {
+        value := string(match[2])
+        if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+            value = value[1:len(value)-1]
+        }
+        attrs[strings.ToLower(string(match[1]))] = value
+    }
+    return attrs
+}
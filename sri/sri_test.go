@@ -0,0 +1,69 @@
+package sri
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+)
+
+func TestAnnotate(t *testing.T) {
+    files := map[string][]byte{
+        "a.js": []byte("var a;"),
+        "a.css": []byte("a{}"),
+    }
+    js := ` integrity="` + Digest(files["a.js"]) + `" crossorigin="anonymous"`
+    css := ` integrity="` + Digest(files["a.css"]) + `" crossorigin="anonymous"`
+    tests := []struct {
+        name string
+        page string
+        annotated string
+        reads []string
+    }{
+        {"script", `<script src="a.js"></script>`, `<script src="a.js"` + js + `></script>`, []string{"a.js"}},
+        {"stylesheet", `<link rel="stylesheet" href='a.css'>`, `<link rel="stylesheet" href='a.css'` + css + `>`, []string{"a.css"}},
+        {"self-closing", `<link href=a.css rel="stylesheet" />`, `<link href=a.css rel="stylesheet" ` + css + `/>`, []string{"a.css"}},
+        {"alternate stylesheet", `<LINK REL="alternate stylesheet" HREF="a.css">`, `<LINK REL="alternate stylesheet" HREF="a.css"` + css + `>`, []string{"a.css"}},
+        {"existing integrity", `<script src="a.js" integrity="sha384-x"></script>`, `<script src="a.js" integrity="sha384-x"></script>`, []string{}},
+        {"existing crossorigin", `<script src="a.js" crossorigin="use-credentials"></script>`,
+            `<script src="a.js" crossorigin="use-credentials" integrity="` + Digest(files["a.js"]) + `"></script>`, []string{"a.js"}},
+        {"external", `<script src="https://cdn.example.com/a.js"></script><script src="//cdn.example.com/a.js"></script>`,
+            `<script src="https://cdn.example.com/a.js"></script><script src="//cdn.example.com/a.js"></script>`, []string{}},
+        {"data url", `<link rel="stylesheet" href="data:text/css,a{}">`, `<link rel="stylesheet" href="data:text/css,a{}">`, []string{}},
+        {"query string", `<script src="a.js?v=2#main"></script>`, `<script src="a.js?v=2#main"` + js + `></script>`, []string{"a.js"}},
+        {"query only", `<script src="?v=2"></script>`, `<script src="?v=2"></script>`, []string{}},
+        {"not a stylesheet", `<link rel="icon" href="a.css"><link rel="preload" href="a.js">`, `<link rel="icon" href="a.css"><link rel="preload" href="a.js">`, []string{}},
+        {"inline script", `<script>var a;</script>`, `<script>var a;</script>`, []string{}},
+        {"not in build", `<script src="b.js"></script>`, `<script src="b.js"></script>`, []string{"b.js"}},
+    }
+    for _, test := range tests {
+        reads := []string{}
+        read := func(url string) ([]byte, error) {
+            reads = append(reads, url)
+            return files[url], nil
+        }
+        annotated, err := Annotate([]byte(test.page), read)
+        if err != nil {
+            t.Errorf("%s: %s", test.name, err)
+            continue
+        }
+        if string(annotated) != test.annotated {
+            t.Errorf("%s: Annotate(%q) = %q, want %q", test.name, test.page, annotated, test.annotated)
+        }
+        if !reflect.DeepEqual(reads, test.reads) {
+            t.Errorf("%s: read %q, want %q", test.name, reads, test.reads)
+        }
+    }
+}
+
+func TestAnnotateReadError(t *testing.T) {
+    page := `<script src="a.js"></script><script src="b.js"></script>`
+    annotated, err := Annotate([]byte(page), func(url string) ([]byte, error) {
+        return nil, errors.New("cannot read " + url)
+    })
+    if err == nil || err.Error() != "cannot read a.js" {
+        t.Errorf("error %v, want the first read's", err)
+    }
+    if string(annotated) != page {
+        t.Errorf("annotated %q, want the page unchanged", annotated)
+    }
+}
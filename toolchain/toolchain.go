@@ -0,0 +1,146 @@
+package toolchain
+
+import (
+    "bytes"
+    "fmt"
+    "os/exec"
+    "regexp"
+    "runtime"
+    "strconv"
+    "strings"
+    "github.com/GlenKelley/dev/command"
+)
+
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// Executor runs tools to ask their versions and LookPath finds them, so
+// checks can be faked.
+var Executor command.Executor = command.System{}
+var LookPath = exec.LookPath
+
+type Tool struct {
+    Name string
+    VersionArgs []string
+    VersionPattern *regexp.Regexp
+    Minimum string
+    Install map[string]string
+}
+
+type Status struct {
+    Tool Tool
+    Path string
+    Version string
+    Err error
+}
+
+func (s Status) OK() bool {
+    return s.Err == nil
+}
+
+func (s Status) Suggestion() string {
+    install := s.Tool.Install[runtime.GOOS]
+    if install == "" {
+        install = s.Tool.Install[""]
+    }
+    return install
+}
+
+var Tools = map[string]Tool {
+    "lessc": Tool{"lessc", []string{"--version"}, nil, "1.3.0", map[string]string{
+        "": "npm install -g less",
+    }},
+    "coffee": Tool{"coffee", []string{"--version"}, nil, "1.6.0", map[string]string{
+        "": "npm install -g coffeescript",
+    }},
+    "markdown": Tool{"markdown", []string{"-V"}, nil, "", map[string]string{
+        "darwin": "brew install discount",
+        "linux": "apt-get install discount",
+    }},
+    "gzip": Tool{"gzip", []string{"--version"}, nil, "1.3.0", map[string]string{
+        "darwin": "brew install gzip",
+        "linux": "apt-get install gzip",
+    }},
+    "perl": Tool{"perl", []string{"-e", "printf '%vd', $^V"}, nil, "5.8.0", map[string]string{
+        "darwin": "brew install perl",
+        "linux": "apt-get install perl",
+    }},
+    "cp": Tool{"cp", nil, nil, "", map[string]string{
+        "linux": "apt-get install coreutils",
+    }},
+    "mv": Tool{"mv", nil, nil, "", map[string]string{
+        "linux": "apt-get install coreutils",
+    }},
+    "go": Tool{"go", []string{"version"}, regexp.MustCompile(`go(\d+\.\d+(?:\.\d+)?)`), "1.18", map[string]string{
+        "darwin": "brew install go",
+        "": "see https://go.dev/doc/install",
+    }},
+    "java": Tool{"java", []string{"-version"}, regexp.MustCompile(`version "([^"]+)"`), "1.7", map[string]string{
+        "darwin": "brew install openjdk",
+        "linux": "apt-get install default-jre",
+    }},
+    "git": Tool{"git", []string{"--version"}, nil, "1.7.0", map[string]string{
+        "darwin": "xcode-select --install",
+        "linux": "apt-get install git",
+    }},
+    "md5": Tool{"md5", nil, nil, "", map[string]string{
+        "darwin": "md5 ships with macOS",
+        "": "install a md5 command compatible with `md5 -q file`, e.g. a wrapper around md5sum",
+    }},
+}
+
+func Check(tool Tool) Status {
+    status := Status{Tool: tool}
+    path, err := LookPath(tool.Name)
+    if err != nil {
+        status.Err = fmt.Errorf("%s not found", tool.Name)
+        return status
+    }
+    status.Path = path
+    if tool.VersionArgs == nil { return status }
+
+    var output bytes.Buffer
+    cmd := command.New(path, tool.VersionArgs...)
+    cmd.Stdout = &output
+    cmd.Stderr = &output
+    Executor.Run(cmd)
+    pattern := tool.VersionPattern
+    if pattern == nil {
+        pattern = versionPattern
+    }
+    match := pattern.FindStringSubmatch(output.String())
+    if match == nil {
+        if tool.Minimum != "" {
+            status.Err = fmt.Errorf("could not determine %s version", tool.Name)
+        }
+        return status
+    }
+    status.Version = match[0]
+    if len(match) > 1 && pattern != versionPattern {
+        status.Version = match[1]
+    }
+    if tool.Minimum != "" && compareVersions(status.Version, tool.Minimum) < 0 {
+        status.Err = fmt.Errorf("%s %s is older than %s", tool.Name, status.Version, tool.Minimum)
+    }
+    return status
+}
+
+func compareVersions(a string, b string) int {
+    as := versionParts(a)
+    bs := versionParts(b)
+    for i := 0; i < len(as); i++ {
+        if as[i] != bs[i] {
+            return as[i] - bs[i]
+        }
+    }
+    return 0
+}
+
+func versionParts(version string) [3]int {
+    parts := [3]int{}
+    match := versionPattern.FindStringSubmatch(version)
+    if match == nil { return parts }
+    for i := range parts {
+        parts[i], _ = strconv.Atoi(strings.TrimSpace(match[i+1]))
+    }
+    return parts
+}
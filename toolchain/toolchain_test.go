@@ -0,0 +1,85 @@
+package toolchain
+
+import (
+    "errors"
+    "io"
+    "reflect"
+    "testing"
+    "github.com/GlenKelley/dev/command"
+)
+
+func TestCheck(t *testing.T) {
+    defer func(executor command.Executor, lookPath func(string) (string, error)) {
+        Executor, LookPath = executor, lookPath
+    }(Executor, LookPath)
+    LookPath = func(name string) (string, error) {
+        if name == "missing" { return "", errors.New("not found") }
+        return "/usr/bin/" + name, nil
+    }
+
+    tests := []struct {
+        tool Tool
+        output string
+        version string
+        err string
+        commands []string
+    }{
+        {Tools["lessc"], "lessc 3.13.1 (Less Compiler)", "3.13.1", "", []string{"/usr/bin/lessc --version"}},
+        {Tools["lessc"], "lessc 1.2.0", "1.2.0", "lessc 1.2.0 is older than 1.3.0", []string{"/usr/bin/lessc --version"}},
+        {Tools["go"], "go version go1.21.3 linux/amd64", "1.21.3", "", []string{"/usr/bin/go version"}},
+        {Tools["go"], "go version go1.9 linux/amd64", "1.9", "go 1.9 is older than 1.18", []string{"/usr/bin/go version"}},
+        {Tools["go"], "go version go1.17.13 darwin/arm64", "1.17.13", "go 1.17.13 is older than 1.18", []string{"/usr/bin/go version"}},
+        {Tools["java"], "openjdk version \"11.0.2\" 2019-01-15", "11.0.2", "", []string{"/usr/bin/java -version"}},
+        {Tools["coffee"], "unknown", "", "could not determine coffee version", []string{"/usr/bin/coffee --version"}},
+        {Tools["mv"], "", "", "", nil},
+        {Tool{Name: "missing"}, "", "", "missing not found", nil},
+    }
+    for _, test := range tests {
+        output := test.output
+        recorder := &command.Recorder{Respond: func(c *command.Cmd) error {
+            _, err := io.WriteString(c.Stderr, output)
+            return err
+        }}
+        Executor = recorder
+        status := Check(test.tool)
+        err := ""
+        if status.Err != nil {
+            err = status.Err.Error()
+        }
+        if status.Version != test.version || err != test.err {
+            t.Errorf("%s: version %q error %q, want %q %q", test.tool.Name, status.Version, err, test.version, test.err)
+        }
+        if commands := recorder.Commands(); !reflect.DeepEqual(commands, append([]string{}, test.commands...)) {
+            t.Errorf("%s: ran %v, want %v", test.tool.Name, commands, test.commands)
+        }
+    }
+}
+
+func TestCompareVersions(t *testing.T) {
+    tests := []struct {
+        a string
+        b string
+        sign int
+    }{
+        {"1.2.3", "1.2.3", 0},
+        {"1.10", "1.9", 1},
+        {"1.3", "1.3.0", 0},
+        {"2", "10.0", -1},
+    }
+    for _, test := range tests {
+        result := compareVersions(test.a, test.b)
+        if sign(result) != test.sign {
+            t.Errorf("compareVersions(%q, %q) = %d, want sign %d", test.a, test.b, result, test.sign)
+        }
+    }
+}
+
+func sign(n int) int {
+    switch {
+    case n < 0:
+        return -1
+    case n > 0:
+        return 1
+    }
+    return 0
+}